@@ -44,12 +44,15 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/perses/common/async"
 	"github.com/perses/common/async/taskhelper"
+	"github.com/perses/common/config"
 	"github.com/perses/common/echo"
 	commonOtel "github.com/perses/common/otel"
 	"github.com/prometheus/client_golang/prometheus"
@@ -64,6 +67,8 @@ var (
 	logMethodTrace bool
 	// http address listened
 	addr string
+	// expose the endpoint reloading the config
+	enableReloadAPI bool
 )
 
 // mainHeader logs the start time and various build information.
@@ -79,6 +84,7 @@ func init() {
 	flag.StringVar(&logLevel, "log.level", "info", "log level. Possible value: panic, fatal, error, warning, info, debug, trace")
 	flag.BoolVar(&logMethodTrace, "log.method-trace", false, "include the calling method as a field in the log. Can be useful to see immediately where the log comes from")
 	flag.StringVar(&addr, "web.listen-address", ":8080", "The address to listen on for HTTP requests, web interface and telemetry.")
+	flag.BoolVar(&enableReloadAPI, "web.enable-reload", false, "Expose the endpoint reloading the config (see web.reload-path). It is not authenticated, so anyone reaching the server can trigger a reload.")
 }
 
 type timerTask struct {
@@ -89,6 +95,7 @@ type timerTask struct {
 type cronTask struct {
 	task     interface{}
 	schedule string
+	opts     taskhelper.CronOptions
 }
 
 type Runner struct {
@@ -98,6 +105,8 @@ type Runner struct {
 	cronTasks []cronTask
 	// timerTasks is the different tasks that are executed periodically.
 	timerTasks []timerTask
+	// startupTasks are executed in order, one after the other, before any other task is started.
+	startupTasks []interface{}
 	// tasks is the different tasks that are executed asynchronously only once time.
 	// for each task an async.TaskRunner will be created
 	tasks []interface{}
@@ -109,6 +118,11 @@ type Runner struct {
 	// If set, then the main header won't be printed.
 	banner           string
 	bannerParameters []interface{}
+	// crashReportDir is the directory where the crash reports are written. If empty, the panics are not caught.
+	crashReportDir string
+	configHash     string
+	// configReloader is used to reload the config when the signal SIGHUP is received or when the reload endpoint is called.
+	configReloader config.Reloader
 }
 
 func NewRunner() *Runner {
@@ -142,6 +156,13 @@ func (r *Runner) WithTasks(t ...interface{}) *Runner {
 	return r
 }
 
+// WithStartupTasks adds tasks that are executed in order, one after the other, before the other tasks are started (e.g. database migrations or cache warm-ups).
+// Each task must return once its job is done. If one of them fails, the application is stopped.
+func (r *Runner) WithStartupTasks(t ...interface{}) *Runner {
+	r.startupTasks = append(r.startupTasks, t...)
+	return r
+}
+
 // WithTimerTasks is the way to add different tasks that will be executed periodically at the frequency defined with the duration.
 func (r *Runner) WithTimerTasks(duration time.Duration, t ...interface{}) *Runner {
 	for _, ts := range t {
@@ -163,11 +184,32 @@ func (r *Runner) WithCronTasks(cronSchedule string, t ...interface{}) *Runner {
 	return r
 }
 
+// WithCronTasksAndOptions is like WithCronTasks but allows monitoring the schedule drift and the missed executions, and catching up after them
+// (including the ones missed while the application was stopped, see taskhelper.CronOptions.StateFile).
+func (r *Runner) WithCronTasksAndOptions(cronSchedule string, opts taskhelper.CronOptions, t ...interface{}) *Runner {
+	for _, ts := range t {
+		r.cronTasks = append(r.cronTasks, cronTask{
+			task:     ts,
+			schedule: cronSchedule,
+			opts:     opts,
+		})
+	}
+	return r
+}
+
 func (r *Runner) WithTaskHelpers(t ...taskhelper.Helper) *Runner {
 	r.helpers = append(r.helpers, t...)
 	return r
 }
 
+// WithConfigReloader forces the reload of the config when the application receives the signal SIGHUP.
+// If the runner has an HTTP server and the flag web.enable-reload is set, the endpoint POST /-/reload is exposed as well (see echo.NewReloadAPI).
+// Usually the reloader is the config.Resolver used to resolve the config of the application.
+func (r *Runner) WithConfigReloader(reloader config.Reloader) *Runner {
+	r.configReloader = reloader
+	return r
+}
+
 func (r *Runner) WithDefaultHTTPServer(metricNamespace string) *Runner {
 	return r.WithDefaultHTTPServerAndPrometheusRegisterer(metricNamespace, prometheus.DefaultRegisterer, prometheus.DefaultGatherer)
 }
@@ -201,6 +243,9 @@ func (r *Runner) OTeLProviderBuilder() *commonOtel.Builder {
 
 // Start will start the application. It is a blocking method and will give back the end once every tasks handled are done.
 func (r *Runner) Start() {
+	if len(r.crashReportDir) > 0 {
+		defer r.recoverMainPanic()
+	}
 	level, err := logrus.ParseLevel(logLevel)
 	if err != nil {
 		logrus.WithError(err).Fatal("unable to set the log.level")
@@ -221,14 +266,60 @@ func (r *Runner) Start() {
 	ctx, cancel := context.WithCancel(context.Background())
 	// in any case, call the cancel method to release any possible resources.
 	defer cancel()
+	if !r.runStartupTasks(ctx) {
+		return
+	}
 	// launch every runner
 	for _, runner := range r.helpers {
-		taskhelper.Run(ctx, cancel, runner)
+		taskhelper.RunWithPanicHandler(ctx, cancel, runner, r.panicHandler())
 	}
 	// Wait for context to be canceled or tasks to be ended and wait for graceful stop
 	taskhelper.JoinAll(ctx, r.waitTimeout, r.helpers)
 }
 
+// runStartupTasks executes the startup tasks in order. It returns false if the application must not go further.
+func (r *Runner) runStartupTasks(ctx context.Context) bool {
+	if len(r.startupTasks) == 0 {
+		return true
+	}
+	// the signal listener is not yet running, so the startup tasks are interrupted with the signals here.
+	startupCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	for _, task := range r.startupTasks {
+		if err := runStartupTask(startupCtx, stop, task); err != nil {
+			logrus.WithError(err).Fatal("a startup task failed")
+		}
+		if startupCtx.Err() != nil {
+			logrus.Info("application stopped during the startup")
+			return false
+		}
+	}
+	return true
+}
+
+func runStartupTask(ctx context.Context, cancel context.CancelFunc, task interface{}) (err error) {
+	simpleTask, ok := task.(async.SimpleTask)
+	if !ok {
+		return fmt.Errorf("task is not a SimpleTask or a Task")
+	}
+	logrus.Infof("running the startup task '%s'", simpleTask.String())
+	ctx = async.WithLogger(ctx, logrus.WithField("task", simpleTask.String()))
+	if t, isTask := task.(async.Task); isTask {
+		if initErr := t.Initialize(); initErr != nil {
+			return fmt.Errorf("unable to call the initialize method of the task '%s': %w", t.String(), initErr)
+		}
+		defer func() {
+			if finalErr := t.Finalize(); finalErr != nil && err == nil {
+				err = fmt.Errorf("unable to call the finalize method of the task '%s': %w", t.String(), finalErr)
+			}
+		}()
+	}
+	if executeErr := simpleTask.Execute(ctx, cancel); executeErr != nil {
+		return fmt.Errorf("unable to call the execute method of the task '%s': %w", simpleTask.String(), executeErr)
+	}
+	return nil
+}
+
 func (r *Runner) printBannerOrMainHeader() {
 	if len(r.banner) == 0 {
 		mainHeader()
@@ -243,6 +334,17 @@ func (r *Runner) printBannerOrMainHeader() {
 }
 
 func (r *Runner) buildTask() {
+	// create the config reloader if defined
+	if r.configReloader != nil {
+		if r.serverBuilder != nil && enableReloadAPI {
+			r.serverBuilder.APIRegistration(echo.NewReloadAPI(r.configReloader))
+		}
+		r.tasks = append(r.tasks, async.NewSignalHandler(func(os.Signal) {
+			if err := r.configReloader.Reload(); err != nil {
+				logrus.WithError(err).Error("unable to reload the config")
+			}
+		}, syscall.SIGHUP))
+	}
 	// create the http server if defined
 	if r.serverBuilder != nil {
 		if serverTask, err := r.serverBuilder.Build(); err != nil {
@@ -264,7 +366,7 @@ func (r *Runner) buildTask() {
 	r.tasks = append(r.tasks, signalsListener)
 
 	for _, c := range r.cronTasks {
-		if taskHelper, err := taskhelper.NewCron(c.task, c.schedule); err != nil {
+		if taskHelper, err := taskhelper.NewCronWithOptions(c.task, c.schedule, c.opts); err != nil {
 			logrus.WithError(err).Fatal("unable to create the taskhelper.Helper to handle a cron set")
 		} else {
 			r.helpers = append(r.helpers, taskHelper)
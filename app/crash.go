@@ -0,0 +1,102 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/perses/common/async/taskhelper"
+	"github.com/perses/common/file"
+	"github.com/prometheus/common/version"
+	"github.com/sirupsen/logrus"
+)
+
+const mainGoroutine = "main"
+
+// WithCrashReport catches the panics occurring in the main go-routine or in the tasks managed by the Runner.
+// Before exiting, a crash report containing the stack trace, the version of the application and the hash of the config is written in dir.
+// Only the hash of the config is written, so no secret can leak in the report, but it still tells whether two crashes happened with the same config.
+// config can be nil.
+// Note that the panics occurring in the go-routines created by the tasks themselves cannot be caught.
+func (r *Runner) WithCrashReport(dir string, config interface{}) *Runner {
+	r.crashReportDir = dir
+	r.configHash = hashConfig(config)
+	return r
+}
+
+func hashConfig(config interface{}) string {
+	if config == nil {
+		return ""
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "unknown"
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// recoverMainPanic must be deferred in the main go-routine.
+func (r *Runner) recoverMainPanic() {
+	if recovered := recover(); recovered != nil {
+		r.crash(mainGoroutine, recovered, debug.Stack())
+	}
+}
+
+func (r *Runner) panicHandler() taskhelper.PanicHandler {
+	if len(r.crashReportDir) == 0 {
+		return nil
+	}
+	return func(helper taskhelper.Helper, recovered interface{}, stack []byte) {
+		r.crash(helper.String(), recovered, stack)
+	}
+}
+
+func (r *Runner) crash(origin string, recovered interface{}, stack []byte) {
+	logger := logrus.WithField("origin", origin)
+	path, err := writeCrashReport(r.crashReportDir, r.configHash, origin, recovered, stack, time.Now().UTC())
+	if err != nil {
+		logger.WithError(err).Error("unable to write the crash report")
+	} else {
+		logger = logger.WithField("crash_report", path)
+	}
+	logger.Fatalf("panic: %v\n%s", recovered, stack)
+}
+
+func writeCrashReport(dir string, configHash string, origin string, recovered interface{}, stack []byte, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", err
+	}
+	report := &strings.Builder{}
+	fmt.Fprintf(report, "time: %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(report, "version: %s\n", version.Version)
+	fmt.Fprintf(report, "revision: %s\n", version.Revision)
+	fmt.Fprintf(report, "build_date: %s\n", version.BuildDate)
+	fmt.Fprintf(report, "go_version: %s\n", runtime.Version())
+	fmt.Fprintf(report, "config_hash: %s\n", configHash)
+	fmt.Fprintf(report, "origin: %s\n", origin)
+	fmt.Fprintf(report, "panic: %v\n\n", recovered)
+	report.Write(stack)
+	path := filepath.Join(dir, fmt.Sprintf("crash-%s.log", now.Format("20060102T150405.000Z")))
+	return path, file.WriteAtomic(path, []byte(report.String()), 0640)
+}
@@ -0,0 +1,41 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCrashReport(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	hash := hashConfig(map[string]string{"foo": "bar"})
+	path, err := writeCrashReport(dir, hash, "my task", "boom", []byte("goroutine 1 [running]:"), now)
+	require.NoError(t, err)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	report := string(data)
+	assert.Contains(t, report, "time: 2024-01-02T03:04:05Z\n")
+	assert.Contains(t, report, "config_hash: "+hash+"\n")
+	assert.Contains(t, report, "origin: my task\n")
+	assert.Contains(t, report, "panic: boom\n")
+	assert.Contains(t, report, "goroutine 1 [running]:")
+	assert.Equal(t, hash, hashConfig(map[string]string{"foo": "bar"}))
+	assert.NotEqual(t, hash, hashConfig(map[string]string{"foo": "baz"}))
+}
@@ -65,3 +65,35 @@ func TestNextImpl_AwaitWithErrorAndContext(t *testing.T) {
 	assert.Equal(t, 2, result)
 	assert.Equal(t, ErrorThrown, err)
 }
+
+func TestNewPromise(t *testing.T) {
+	future, resolve, reject := NewPromise[int]()
+	go func() {
+		resolve(3)
+		// ignored since the promise is already resolved
+		reject(ErrorThrown)
+	}()
+	result, err := future.Await()
+	assert.Equal(t, 3, result)
+	assert.NoError(t, err)
+
+	rejected, _, rejectFunc := NewPromise[int]()
+	rejectFunc(ErrorThrown)
+	_, err = rejected.Await()
+	assert.Equal(t, ErrorThrown, err)
+
+	pending, _, _ := NewPromise[int]()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pending.AwaitWithContext(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestTaskFunc(t *testing.T) {
+	task := TaskFunc("failing", func(_ context.Context) error { return ErrorThrown })
+	assert.Equal(t, "failing", task.String())
+	assert.Equal(t, ErrorThrown, task.Execute(context.Background(), func() {}))
+
+	cronTask := CronTaskFunc("failing", func(_ context.Context) error { return ErrorThrown })
+	assert.NoError(t, cronTask.Execute(context.Background(), func() {}))
+}
@@ -0,0 +1,141 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package async
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Batcher accumulates items and flushes them when maxItems items are buffered or when the oldest item has waited for maxDelay.
+// It is a Task: it must be run (with the app.Runner for example) to flush on time, and it flushes the remaining items when it is finalized.
+type Batcher[T any] struct {
+	Task
+	maxItems int
+	maxDelay time.Duration
+	flush    func(ctx context.Context, items []T) error
+	mutex    sync.Mutex
+	buffer   []T
+	// notify is used to wake up the go-routine running the Batcher when the buffer becomes non-empty or full.
+	notify chan struct{}
+}
+
+// NewBatcher returns a Batcher calling flush with the items accumulated. The errors returned by flush are logged, the items are not retried.
+func NewBatcher[T any](maxItems int, maxDelay time.Duration, flush func(ctx context.Context, items []T) error) (*Batcher[T], error) {
+	if maxItems < 1 {
+		return nil, fmt.Errorf("maxItems must be greater than 0")
+	}
+	if maxDelay <= 0 {
+		return nil, fmt.Errorf("maxDelay must be greater than 0")
+	}
+	return &Batcher[T]{
+		maxItems: maxItems,
+		maxDelay: maxDelay,
+		flush:    flush,
+		buffer:   make([]T, 0, maxItems),
+		notify:   make(chan struct{}, 1),
+	}, nil
+}
+
+// Add appends the items to the buffer.
+func (b *Batcher[T]) Add(items ...T) {
+	b.mutex.Lock()
+	wasEmpty := len(b.buffer) == 0
+	b.buffer = append(b.buffer, items...)
+	shouldNotify := len(b.buffer) > 0 && (wasEmpty || len(b.buffer) >= b.maxItems)
+	b.mutex.Unlock()
+	if shouldNotify {
+		select {
+		case b.notify <- struct{}{}:
+		default:
+			// a notification is already pending
+		}
+	}
+}
+
+func (b *Batcher[T]) String() string {
+	return "batcher"
+}
+
+func (b *Batcher[T]) Initialize() error {
+	return nil
+}
+
+func (b *Batcher[T]) Execute(ctx context.Context, _ context.CancelFunc) error {
+	var timer *time.Timer
+	var timerC <-chan time.Time
+	stopTimer := func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		timerC = nil
+	}
+	defer stopTimer()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-b.notify:
+			if b.size() >= b.maxItems {
+				stopTimer()
+				b.flushBuffer(ctx, false)
+			}
+		case <-timerC:
+			timerC = nil
+			b.flushBuffer(ctx, true)
+		}
+		if timerC == nil && b.size() > 0 {
+			timer = time.NewTimer(b.maxDelay)
+			timerC = timer.C
+		}
+	}
+}
+
+// Finalize flushes the remaining items.
+func (b *Batcher[T]) Finalize() error {
+	b.flushBuffer(context.Background(), true)
+	return nil
+}
+
+func (b *Batcher[T]) size() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.buffer)
+}
+
+// flushBuffer flushes the buffer by chunks of maxItems. If all is false, the last chunk is kept in the buffer if it is not full.
+func (b *Batcher[T]) flushBuffer(ctx context.Context, all bool) {
+	for {
+		b.mutex.Lock()
+		if len(b.buffer) == 0 {
+			b.mutex.Unlock()
+			return
+		}
+		n := min(len(b.buffer), b.maxItems)
+		items := b.buffer[:n:n]
+		b.buffer = append(make([]T, 0, b.maxItems), b.buffer[n:]...)
+		next := len(b.buffer) >= b.maxItems || (all && len(b.buffer) > 0)
+		b.mutex.Unlock()
+		if err := b.flush(ctx, items); err != nil {
+			logrus.WithError(err).Errorf("unable to flush %d items", len(items))
+		}
+		if !next {
+			return
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package async
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type flushRecorder struct {
+	mutex   sync.Mutex
+	batches [][]int
+}
+
+func (f *flushRecorder) flush(_ context.Context, items []int) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.batches = append(f.batches, items)
+	return nil
+}
+
+func (f *flushRecorder) get() [][]int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([][]int{}, f.batches...)
+}
+
+func TestBatcher(t *testing.T) {
+	recorder := &flushRecorder{}
+	batcher, err := NewBatcher(3, 50*time.Millisecond, recorder.flush)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = batcher.Execute(ctx, cancel)
+		close(done)
+	}()
+
+	// flushed on size
+	batcher.Add(1, 2, 3, 4)
+	assert.Eventually(t, func() bool { return len(recorder.get()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, []int{1, 2, 3}, recorder.get()[0])
+	// flushed on time
+	assert.Eventually(t, func() bool { return len(recorder.get()) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, []int{4}, recorder.get()[1])
+
+	// flushed when finalized
+	batcher.Add(5)
+	cancel()
+	<-done
+	assert.NoError(t, batcher.Finalize())
+	assert.Equal(t, []int{5}, recorder.get()[2])
+}
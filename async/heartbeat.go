@@ -0,0 +1,56 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package async
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+type heartbeatKey struct{}
+
+// HeartbeatRecorder keeps the time of the last heartbeat sent by a task.
+type HeartbeatRecorder struct {
+	last atomic.Int64
+}
+
+// NewHeartbeatRecorder returns a recorder whose last heartbeat is now.
+func NewHeartbeatRecorder() *HeartbeatRecorder {
+	h := &HeartbeatRecorder{}
+	h.Beat()
+	return h
+}
+
+func (h *HeartbeatRecorder) Beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+// Last returns the time of the last heartbeat.
+func (h *HeartbeatRecorder) Last() time.Time {
+	return time.Unix(0, h.last.Load())
+}
+
+// WithHeartbeatRecorder returns a copy of the context carrying the recorder. The heartbeats sent with Heartbeat on this context are recorded in it.
+func WithHeartbeatRecorder(ctx context.Context, recorder *HeartbeatRecorder) context.Context {
+	return context.WithValue(ctx, heartbeatKey{}, recorder)
+}
+
+// Heartbeat reports that the task owning the context is making progress. Long-running tasks should call it regularly,
+// so a stuck task can be detected (see taskhelper.WithHeartbeat). It does nothing if the context carries no HeartbeatRecorder.
+func Heartbeat(ctx context.Context) {
+	if recorder, ok := ctx.Value(heartbeatKey{}).(*HeartbeatRecorder); ok {
+		recorder.Beat()
+	}
+}
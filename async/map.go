@@ -0,0 +1,95 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Map applies fn on every item with at most limit calls running at the same time (no limit if limit <= 0).
+// The results keep the order of the items. On the first error, the context passed to the other calls is canceled,
+// no new call is started and the error is returned.
+func Map[T any, U any](ctx context.Context, items []T, limit int, fn func(ctx context.Context, item T) (U, error)) ([]U, error) {
+	return mapItems(ctx, items, limit, true, fn)
+}
+
+// MapAll is like Map but every item is processed even if some calls fail. The results of the failed calls are left empty,
+// and the errors are joined (see errors.Join).
+func MapAll[T any, U any](ctx context.Context, items []T, limit int, fn func(ctx context.Context, item T) (U, error)) ([]U, error) {
+	return mapItems(ctx, items, limit, false, fn)
+}
+
+func mapItems[T any, U any](ctx context.Context, items []T, limit int, stopOnError bool, fn func(ctx context.Context, item T) (U, error)) ([]U, error) {
+	if limit <= 0 || limit > len(items) {
+		limit = len(items)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]U, len(items))
+	errs := make([]error, len(items))
+	slots := make(chan struct{}, limit)
+	wg := sync.WaitGroup{}
+	var firstErr error
+	var once sync.Once
+	// interrupted is true when the context is canceled before every item has been started
+	interrupted := false
+loop:
+	for i, item := range items {
+		select {
+		case <-ctx.Done():
+			interrupted = true
+			break loop
+		case slots <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			// the slot may have been released by a call that has just failed
+			<-slots
+			interrupted = true
+			break
+		}
+		wg.Add(1)
+		go func(i int, item T) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			result, err := fn(ctx, item)
+			if err != nil {
+				errs[i] = err
+				if stopOnError {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+				return
+			}
+			results[i] = result
+		}(i, item)
+	}
+	wg.Wait()
+	if stopOnError {
+		if firstErr != nil {
+			return nil, firstErr
+		}
+	} else if err := errors.Join(errs...); err != nil {
+		return results, err
+	}
+	if interrupted {
+		return results, ctx.Err()
+	}
+	return results, nil
+}
@@ -0,0 +1,69 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package async
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	items := []int{5, 4, 3, 2, 1, 0}
+	results, err := Map(context.Background(), items, 2, func(_ context.Context, item int) (string, error) {
+		current := running.Add(1)
+		defer running.Add(-1)
+		for {
+			old := maxRunning.Load()
+			if current <= old || maxRunning.CompareAndSwap(old, current) {
+				break
+			}
+		}
+		time.Sleep(time.Duration(item) * time.Millisecond)
+		return strconv.Itoa(item), nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"5", "4", "3", "2", "1", "0"}, results)
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+}
+
+func TestMap_StopOnError(t *testing.T) {
+	var calls atomic.Int32
+	_, err := Map(context.Background(), []int{1, 2, 3, 4, 5}, 1, func(_ context.Context, item int) (int, error) {
+		calls.Add(1)
+		if item == 2 {
+			return 0, ErrorThrown
+		}
+		return item, nil
+	})
+	assert.Equal(t, ErrorThrown, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestMapAll(t *testing.T) {
+	results, err := MapAll(context.Background(), []int{1, 2, 3}, 0, func(_ context.Context, item int) (int, error) {
+		if item == 2 {
+			return 0, ErrorThrown
+		}
+		return item * 2, nil
+	})
+	assert.True(t, errors.Is(err, ErrorThrown))
+	assert.Equal(t, []int{2, 0, 6}, results)
+}
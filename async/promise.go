@@ -0,0 +1,62 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package async
+
+import (
+	"context"
+	"sync"
+)
+
+// NewPromise returns a Future that is completed from the outside by calling resolve or reject.
+// It is useful to bridge a callback-based library into the Future API. Only the first call to resolve or reject is considered.
+//
+//	future, resolve, reject := async.NewPromise[Event]()
+//	consumer.OnMessage(func(msg Message) {
+//	    event, err := decode(msg)
+//	    if err != nil {
+//	        reject(err)
+//	        return
+//	    }
+//	    resolve(event)
+//	})
+//	event, err := future.AwaitWithContext(ctx)
+func NewPromise[T any]() (future Future[T], resolve func(T), reject func(error)) {
+	var result T
+	var resultError error
+	var once sync.Once
+	c := make(chan struct{})
+	complete := func(value T, err error) {
+		once.Do(func() {
+			result, resultError = value, err
+			close(c)
+		})
+	}
+	future = &next[T]{
+		await: func(ctx context.Context) (T, error) {
+			select {
+			case <-ctx.Done():
+				return emptyValue[T](), ctx.Err()
+			case <-c:
+				return result, resultError
+			}
+		},
+	}
+	resolve = func(value T) {
+		complete(value, nil)
+	}
+	reject = func(err error) {
+		complete(emptyValue[T](), err)
+	}
+	return future, resolve, reject
+}
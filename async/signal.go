@@ -48,3 +48,38 @@ func (s *signalListener) Execute(ctx context.Context, cancelFunc context.CancelF
 	}
 	return nil
 }
+
+type signalHandler struct {
+	SimpleTask
+	handler func(sig os.Signal)
+	signals []os.Signal
+}
+
+// NewSignalHandler returns a task calling the handler every time one of the signals is received, until the task is canceled.
+// Unlike NewSignalListener, it doesn't stop the application. It can be used to reload the config on SIGHUP for example.
+func NewSignalHandler(handler func(sig os.Signal), signals ...os.Signal) SimpleTask {
+	return &signalHandler{
+		handler: handler,
+		signals: signals,
+	}
+}
+
+func (s *signalHandler) String() string {
+	return "signal handler"
+}
+
+func (s *signalHandler) Execute(ctx context.Context, _ context.CancelFunc) error {
+	sigChannel := make(chan os.Signal, 1)
+	signal.Notify(sigChannel, s.signals...)
+	defer signal.Stop(sigChannel)
+	for {
+		select {
+		case sig := <-sigChannel:
+			logrus.Infof("signal received: %s", sig)
+			s.handler(sig)
+		case <-ctx.Done():
+			logrus.Debugf("task '%s' has been canceled", s.String())
+			return nil
+		}
+	}
+}
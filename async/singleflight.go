@@ -0,0 +1,126 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package async
+
+import (
+	"sync"
+	"time"
+)
+
+type call[V any] struct {
+	done   chan struct{}
+	result V
+	err    error
+}
+
+// SingleFlight deduplicates the concurrent calls sharing the same key: while a call is running, the other callers with the same key
+// wait for it and get the same result instead of executing the function again.
+// The zero value is ready to use.
+type SingleFlight[K comparable, V any] struct {
+	mutex sync.Mutex
+	calls map[K]*call[V]
+}
+
+// Do executes fn if there is no call in progress for the key. Otherwise, it waits for the call in progress and returns its result.
+func (s *SingleFlight[K, V]) Do(key K, fn func() (V, error)) (V, error) {
+	s.mutex.Lock()
+	if s.calls == nil {
+		s.calls = make(map[K]*call[V])
+	}
+	if c, ok := s.calls[key]; ok {
+		s.mutex.Unlock()
+		<-c.done
+		return c.result, c.err
+	}
+	c := &call[V]{done: make(chan struct{})}
+	s.calls[key] = c
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.calls, key)
+		s.mutex.Unlock()
+		close(c.done)
+	}()
+	c.result, c.err = fn()
+	return c.result, c.err
+}
+
+type memoEntry[V any] struct {
+	future Future[V]
+	// expiresAt is zero while the call is running
+	expiresAt time.Time
+}
+
+// Memo caches the result of a function by key for a duration. The concurrent calls for the same key share the same Future,
+// so the function is executed only once. The errors are not cached: the next call after a failure executes the function again.
+// The expired entries are removed when they are accessed or when Purge is called.
+type Memo[K comparable, V any] struct {
+	ttl     time.Duration
+	fn      func(key K) (V, error)
+	mutex   sync.Mutex
+	entries map[K]*memoEntry[V]
+}
+
+func NewMemo[K comparable, V any](ttl time.Duration, fn func(key K) (V, error)) *Memo[K, V] {
+	return &Memo[K, V]{
+		ttl:     ttl,
+		fn:      fn,
+		entries: make(map[K]*memoEntry[V]),
+	}
+}
+
+// Get returns the Future of the result for the key. The function is executed asynchronously if there is no valid result in the cache.
+func (m *Memo[K, V]) Get(key K) Future[V] {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if entry, ok := m.entries[key]; ok && (entry.expiresAt.IsZero() || time.Now().Before(entry.expiresAt)) {
+		return entry.future
+	}
+	entry := &memoEntry[V]{}
+	entry.future = Async(func() (V, error) {
+		result, err := m.fn(key)
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		if m.entries[key] == entry {
+			if err != nil {
+				delete(m.entries, key)
+			} else {
+				entry.expiresAt = time.Now().Add(m.ttl)
+			}
+		}
+		return result, err
+	})
+	m.entries[key] = entry
+	return entry.future
+}
+
+// Forget removes the result of the key from the cache.
+func (m *Memo[K, V]) Forget(key K) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.entries, key)
+}
+
+// Purge removes every expired result from the cache.
+func (m *Memo[K, V]) Purge() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	for key, entry := range m.entries {
+		if !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+			delete(m.entries, key)
+		}
+	}
+}
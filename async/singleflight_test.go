@@ -0,0 +1,76 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package async
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSingleFlight_Do(t *testing.T) {
+	var calls atomic.Int32
+	var s SingleFlight[string, int]
+	release := make(chan struct{})
+	wg := sync.WaitGroup{}
+	results := make([]int, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = s.Do("key", func() (int, error) {
+				calls.Add(1)
+				<-release
+				return 42, nil
+			})
+		}(i)
+	}
+	// let the goroutines join the call in progress
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+	for _, result := range results {
+		assert.Equal(t, 42, result)
+	}
+}
+
+func TestMemo_Get(t *testing.T) {
+	var calls atomic.Int32
+	memo := NewMemo(50*time.Millisecond, func(key string) (int, error) {
+		calls.Add(1)
+		if key == "error" {
+			return 0, ErrorThrown
+		}
+		return len(key), nil
+	})
+	result, err := memo.Get("perses").Await()
+	assert.NoError(t, err)
+	assert.Equal(t, 6, result)
+	_, _ = memo.Get("perses").Await()
+	assert.Equal(t, int32(1), calls.Load())
+
+	// errors are not cached
+	_, err = memo.Get("error").Await()
+	assert.Equal(t, ErrorThrown, err)
+	_, _ = memo.Get("error").Await()
+	assert.Equal(t, int32(3), calls.Load())
+
+	time.Sleep(60 * time.Millisecond)
+	_, _ = memo.Get("perses").Await()
+	assert.Equal(t, int32(4), calls.Load())
+}
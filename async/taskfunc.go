@@ -0,0 +1,52 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package async
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type taskFunc struct {
+	SimpleTask
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// TaskFunc turns a function into a SimpleTask named name, so a small job doesn't need a dedicated struct.
+//
+//	app.NewRunner().WithTimerTasks(time.Minute, async.TaskFunc("cleanup", cleanup))
+func TaskFunc(name string, fn func(ctx context.Context) error) SimpleTask {
+	return &taskFunc{name: name, fn: fn}
+}
+
+// CronTaskFunc is like TaskFunc but the errors are logged instead of being returned.
+// It is meant for the periodic tasks (see app.Runner.WithCronTasks and app.Runner.WithTimerTasks): an execution in error doesn't stop the next ones.
+func CronTaskFunc(name string, fn func(ctx context.Context) error) SimpleTask {
+	return &taskFunc{name: name, fn: func(ctx context.Context) error {
+		if err := fn(ctx); err != nil {
+			logrus.WithError(err).Errorf("execution of the task '%s' failed", name)
+		}
+		return nil
+	}}
+}
+
+func (t *taskFunc) String() string {
+	return t.name
+}
+
+func (t *taskFunc) Execute(ctx context.Context, _ context.CancelFunc) error {
+	return t.fn(ctx)
+}
@@ -43,12 +43,12 @@ func (r *runner) String() string {
 func (r *runner) Start(ctx context.Context, cancelFunc context.CancelFunc) (err error) {
 	// closing this channel will highlight the caller that the task is done.
 	defer close(r.done)
-	childCtx := ctx
+	childCtx := async.WithLogger(ctx, logrus.WithField("task", r.String()))
 	if !r.isSimpleTask {
 		// childCancelFunc will be used to stop any sub go-routing using the childCtx when the current task is stopped.
 		// it's just to be sure that every sub go-routing created by the task will be stopped without stopping the whole application.
 		var childCancelFunc context.CancelFunc
-		childCtx, childCancelFunc = context.WithCancel(ctx)
+		childCtx, childCancelFunc = context.WithCancel(childCtx)
 		t := r.task.(async.Task)
 		// then we have to call the finalize method of the task
 		defer func() {
@@ -74,6 +74,7 @@ func (r *runner) Start(ctx context.Context, cancelFunc context.CancelFunc) (err
 		err = fmt.Errorf("unable to call the execute method of the task: %w", executeErr)
 		return
 	}
+	async.Heartbeat(childCtx)
 
 	// in case the runner has an interval properly set, then we can create a ticker and periodically call the method that executes the task
 	return r.tick(childCtx, cancelFunc)
@@ -92,6 +93,7 @@ func (r *runner) tick(ctx context.Context, cancelFunc context.CancelFunc) error
 			if executeErr := simpleTask.Execute(ctx, cancelFunc); executeErr != nil {
 				return fmt.Errorf("unable to call the execute method of the task %s: %w", simpleTask.String(), executeErr)
 			}
+			async.Heartbeat(ctx)
 		case <-ctx.Done():
 			logrus.Debugf("task %s has been canceled", simpleTask.String())
 			return nil
@@ -19,6 +19,8 @@ import (
 	"time"
 
 	"github.com/perses/common/async"
+	"github.com/perses/common/promhelper"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/robfig/cron"
 	"github.com/sirupsen/logrus"
 )
@@ -31,6 +33,43 @@ type cronRunner struct {
 	task         interface{}
 	isSimpleTask bool
 	done         chan struct{}
+	opts         CronOptions
+	metrics      *cronMetrics
+}
+
+// CronOptions configures the Helper returned by NewCronWithOptions.
+type CronOptions struct {
+	// CatchUp executes the task once more right away when scheduled executions have been missed
+	// (because an execution overran or the process has been suspended).
+	CatchUp bool
+	// StateFile is the file where the time of the last execution of the task is recorded, so the executions missed while the application
+	// was stopped can be detected at the next start. The file can be shared by multiple cron tasks as long as they have different names.
+	// When empty, nothing is recorded and the task just waits for its next scheduled execution when the application starts.
+	StateFile string
+	// Misfire is the policy applied when the StateFile shows that executions have been missed while the application was stopped.
+	// By default, MisfireSkip is used.
+	Misfire MisfirePolicy
+	// PrometheusRegisterer is used to expose the metrics cron_task_schedule_drift_seconds and cron_task_missed_runs_total.
+	// When nil, no metric is exposed.
+	PrometheusRegisterer prometheus.Registerer
+}
+
+type cronMetrics struct {
+	drift      *prometheus.GaugeVec
+	missedRuns *prometheus.CounterVec
+}
+
+func newCronMetrics(r prometheus.Registerer) *cronMetrics {
+	return &cronMetrics{
+		drift: promhelper.RegisterOrReuseOrLog(r, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cron_task_schedule_drift_seconds",
+			Help: "Delay between the time a cron task was scheduled and the time its last execution started",
+		}, []string{labelTask})),
+		missedRuns: promhelper.RegisterOrReuseOrLog(r, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cron_task_missed_runs_total",
+			Help: "Total of scheduled executions of a cron task that have been skipped",
+		}, []string{labelTask})),
+	}
 }
 
 func (r *cronRunner) Done() <-chan struct{} {
@@ -44,12 +83,12 @@ func (r *cronRunner) String() string {
 func (r *cronRunner) Start(ctx context.Context, cancelFunc context.CancelFunc) (err error) {
 	// closing this channel will highlight the caller that the task is done.
 	defer close(r.done)
-	childCtx := ctx
+	childCtx := async.WithLogger(ctx, logrus.WithField("task", r.String()))
 	if !r.isSimpleTask {
 		// childCancelFunc will be used to stop any sub go-routing using the childCtx when the current task is stopped.
 		// it's just to be sure that every sub go-routing created by the task will be stopped without stopping the whole application.
 		var childCancelFunc context.CancelFunc
-		childCtx, childCancelFunc = context.WithCancel(ctx)
+		childCtx, childCancelFunc = context.WithCancel(childCtx)
 		t := r.task.(async.Task)
 		// then we have to call the finalize method of the task
 		defer func() {
@@ -74,23 +113,108 @@ func (r *cronRunner) Start(ctx context.Context, cancelFunc context.CancelFunc) (
 
 func (r *cronRunner) cron(ctx context.Context, cancelFunc context.CancelFunc) error {
 	simpleTask := r.task.(async.SimpleTask)
-	now := time.Now()
-	next := r.schedule.Next(now)
+	next, err := r.firstRun(ctx, cancelFunc)
+	if err != nil {
+		return err
+	}
 	for {
-		timer := time.NewTimer(next.Sub(now))
-		for {
-			select {
-			case now = <-timer.C:
-				// then run the task
-				if executeErr := r.task.(async.SimpleTask).Execute(ctx, cancelFunc); executeErr != nil {
-					return fmt.Errorf("unable to call the execute method of the task: %w", executeErr)
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case now := <-timer.C:
+			r.observeDrift(now.Sub(next))
+			if err := r.execute(ctx, cancelFunc); err != nil {
+				return err
+			}
+			r.recordRun(next)
+			var missed int
+			next, missed = r.nextRun(next)
+			if missed == 0 {
+				continue
+			}
+			// the execution overran or the process has been suspended
+			logrus.Warningf("task %s missed %d scheduled execution(s)", simpleTask.String(), missed)
+			if r.metrics != nil {
+				r.metrics.missedRuns.WithLabelValues(simpleTask.String()).Add(float64(missed))
+			}
+			if r.opts.CatchUp {
+				now := time.Now()
+				if err := r.execute(ctx, cancelFunc); err != nil {
+					return err
 				}
-				next = r.schedule.Next(now)
-			case <-ctx.Done():
-				logrus.Debugf("task %s has been canceled", simpleTask.String())
-				return nil
+				r.recordRun(now)
+				next = r.schedule.Next(time.Now())
 			}
-			break
+		case <-ctx.Done():
+			timer.Stop()
+			logrus.Debugf("task %s has been canceled", simpleTask.String())
+			return nil
 		}
 	}
 }
+
+// firstRun returns the time of the first execution. When the state file shows executions have been missed while the application was stopped,
+// the misfire policy is applied.
+func (r *cronRunner) firstRun(ctx context.Context, cancelFunc context.CancelFunc) (time.Time, error) {
+	if len(r.opts.StateFile) == 0 {
+		return r.schedule.Next(time.Now()), nil
+	}
+	last, ok, err := loadLastRun(r.opts.StateFile, r.String())
+	if err != nil {
+		logrus.WithError(err).Errorf("unable to read the last execution of the task %s", r.String())
+	}
+	if !ok {
+		return r.schedule.Next(time.Now()), nil
+	}
+	next, missed := r.nextRun(last)
+	if missed == 0 {
+		return next, nil
+	}
+	logrus.Warningf("task %s missed %d scheduled execution(s) while the application was stopped", r.String(), missed)
+	if r.metrics != nil {
+		r.metrics.missedRuns.WithLabelValues(r.String()).Add(float64(missed))
+	}
+	if r.opts.Misfire != MisfireRunImmediately {
+		return next, nil
+	}
+	now := time.Now()
+	if err := r.execute(ctx, cancelFunc); err != nil {
+		return time.Time{}, err
+	}
+	r.recordRun(now)
+	return r.schedule.Next(time.Now()), nil
+}
+
+func (r *cronRunner) recordRun(scheduled time.Time) {
+	if len(r.opts.StateFile) == 0 {
+		return
+	}
+	if err := saveLastRun(r.opts.StateFile, r.String(), scheduled); err != nil {
+		logrus.WithError(err).Errorf("unable to record the last execution of the task %s", r.String())
+	}
+}
+
+func (r *cronRunner) execute(ctx context.Context, cancelFunc context.CancelFunc) error {
+	if executeErr := r.task.(async.SimpleTask).Execute(ctx, cancelFunc); executeErr != nil {
+		return fmt.Errorf("unable to call the execute method of the task: %w", executeErr)
+	}
+	async.Heartbeat(ctx)
+	return nil
+}
+
+// nextRun returns the next execution in the future after the one scheduled at last, and the number of executions skipped because they are already in the past.
+func (r *cronRunner) nextRun(last time.Time) (time.Time, int) {
+	now := time.Now()
+	next := r.schedule.Next(last)
+	missed := 0
+	for !next.After(now) {
+		missed++
+		next = r.schedule.Next(next)
+	}
+	return next, missed
+}
+
+func (r *cronRunner) observeDrift(drift time.Duration) {
+	if r.metrics != nil {
+		r.metrics.drift.WithLabelValues(r.String()).Set(drift.Seconds())
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskhelper
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/perses/common/async"
+	"github.com/robfig/cron"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronRunner_NextRun(t *testing.T) {
+	r := &cronRunner{schedule: cron.Every(time.Second)}
+	now := time.Now().Truncate(time.Second)
+
+	next, missed := r.nextRun(now.Add(-3 * time.Second))
+	assert.Equal(t, 3, missed)
+	assert.Equal(t, now.Add(time.Second), next)
+
+	next, missed = r.nextRun(now)
+	assert.Equal(t, 0, missed)
+	assert.Equal(t, now.Add(time.Second), next)
+}
+
+func TestCronRunner_Misfire(t *testing.T) {
+	testSuites := []struct {
+		title          string
+		policy         MisfirePolicy
+		lastRun        time.Duration
+		expectedCalled int
+	}{
+		{title: "no execution missed", policy: MisfireRunImmediately, lastRun: -time.Minute},
+		{title: "skip the executions missed", policy: MisfireSkip, lastRun: -3 * time.Hour},
+		{title: "run immediately after executions missed", policy: MisfireRunImmediately, lastRun: -3 * time.Hour, expectedCalled: 1},
+	}
+	for _, test := range testSuites {
+		t.Run(test.title, func(t *testing.T) {
+			stateFile := filepath.Join(t.TempDir(), "state.json")
+			called := 0
+			task := async.TaskFunc("my task", func(_ context.Context) error {
+				called++
+				return nil
+			})
+			lastRun := time.Now().Add(test.lastRun).Truncate(time.Second)
+			require.NoError(t, saveLastRun(stateFile, "my task", lastRun))
+			helper, err := NewCronWithOptions(task, "@hourly", CronOptions{StateFile: stateFile, Misfire: test.policy})
+			require.NoError(t, err)
+			r := helper.(*cronRunner)
+			r.schedule = cron.Every(time.Hour)
+
+			next, err := r.firstRun(context.Background(), func() {})
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCalled, called)
+			assert.True(t, next.After(time.Now()))
+			recorded, ok, err := loadLastRun(stateFile, "my task")
+			require.NoError(t, err)
+			assert.True(t, ok)
+			if test.expectedCalled > 0 {
+				assert.True(t, recorded.After(lastRun))
+			} else {
+				assert.True(t, recorded.Equal(lastRun))
+			}
+		})
+	}
+}
+
+func TestNewCronWithOptions_UnknownMisfire(t *testing.T) {
+	_, err := NewCronWithOptions(async.TaskFunc("my task", nil), "@hourly", CronOptions{Misfire: "unknown"})
+	assert.Error(t, err)
+}
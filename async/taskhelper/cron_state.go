@@ -0,0 +1,81 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskhelper
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/perses/common/file"
+)
+
+// MisfirePolicy tells what to do when scheduled executions of a cron task have been missed while the application was stopped.
+type MisfirePolicy string
+
+const (
+	// MisfireSkip ignores the executions missed and waits for the next one scheduled.
+	MisfireSkip MisfirePolicy = "skip"
+	// MisfireRunImmediately executes the task once as soon as the application starts.
+	MisfireRunImmediately MisfirePolicy = "run_immediately"
+)
+
+// stateMutex protects the state files as they can be shared by multiple cron tasks.
+var stateMutex sync.Mutex
+
+// loadLastRun returns the time of the last execution of the task recorded in the state file.
+// It returns false if the task has never been recorded.
+func loadLastRun(filename string, task string) (time.Time, bool, error) {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	state, err := readState(filename)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	last, ok := state[task]
+	return last, ok, nil
+}
+
+// saveLastRun records in the state file the time of the last execution of the task.
+func saveLastRun(filename string, task string, last time.Time) error {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	state, err := readState(filename)
+	if err != nil {
+		return err
+	}
+	state[task] = last
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return file.WriteAtomic(filename, data, 0640)
+}
+
+func readState(filename string) (map[string]time.Time, error) {
+	state := make(map[string]time.Time)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return state, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
@@ -0,0 +1,95 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskhelper
+
+import (
+	"context"
+	"time"
+
+	"github.com/perses/common/async"
+	"github.com/perses/common/promhelper"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const labelTask = "task"
+
+type heartbeatMetrics struct {
+	lastHeartbeat *prometheus.GaugeVec
+	stalled       *prometheus.GaugeVec
+}
+
+type heartbeatHelper struct {
+	Helper
+	window  time.Duration
+	metrics *heartbeatMetrics
+}
+
+// WithHeartbeat wraps the Helper to detect when its task is stuck: the task is considered stalled when it hasn't sent a heartbeat
+// (see async.Heartbeat) for longer than window. A stalled task is logged and flagged by the metric task_heartbeat_stalled.
+// The periodic tasks (see NewTick and NewCron) send a heartbeat automatically after every execution.
+// When r is nil, no metric is exposed.
+func WithHeartbeat(helper Helper, window time.Duration, r prometheus.Registerer) Helper {
+	h := &heartbeatHelper{Helper: helper, window: window}
+	if r != nil {
+		h.metrics = &heartbeatMetrics{
+			lastHeartbeat: promhelper.RegisterOrReuseOrLog(r, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "task_last_heartbeat_timestamp_seconds",
+				Help: "Timestamp of the last heartbeat sent by the task",
+			}, []string{labelTask})),
+			stalled: promhelper.RegisterOrReuseOrLog(r, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "task_heartbeat_stalled",
+				Help: "1 if the task hasn't sent a heartbeat within the expected window, 0 otherwise",
+			}, []string{labelTask})),
+		}
+	}
+	return h
+}
+
+func (h *heartbeatHelper) Start(ctx context.Context, cancelFunc context.CancelFunc) error {
+	recorder := async.NewHeartbeatRecorder()
+	monitorCtx, stopMonitor := context.WithCancel(ctx)
+	defer stopMonitor()
+	go h.monitor(monitorCtx, recorder)
+	return h.Helper.Start(async.WithHeartbeatRecorder(ctx, recorder), cancelFunc)
+}
+
+func (h *heartbeatHelper) monitor(ctx context.Context, recorder *async.HeartbeatRecorder) {
+	ticker := time.NewTicker(max(h.window/2, time.Millisecond))
+	defer ticker.Stop()
+	wasStalled := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		last := recorder.Last()
+		stalled := time.Since(last) > h.window
+		if stalled && !wasStalled {
+			logrus.Warningf("task '%s' hasn't sent a heartbeat since %s", h.String(), last.Format(time.RFC3339))
+		} else if !stalled && wasStalled {
+			logrus.Infof("task '%s' is sending heartbeats again", h.String())
+		}
+		wasStalled = stalled
+		if h.metrics != nil {
+			h.metrics.lastHeartbeat.WithLabelValues(h.String()).Set(float64(last.UnixNano()) / float64(time.Second))
+			value := 0.0
+			if stalled {
+				value = 1
+			}
+			h.metrics.stalled.WithLabelValues(h.String()).Set(value)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package taskhelper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/perses/common/async"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type heartbeatTask struct {
+	async.SimpleTask
+	name  string
+	beats bool
+}
+
+func (h *heartbeatTask) String() string {
+	return h.name
+}
+
+func (h *heartbeatTask) Execute(ctx context.Context, _ context.CancelFunc) error {
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if h.beats {
+				async.Heartbeat(ctx)
+			}
+		}
+	}
+}
+
+func TestWithHeartbeat(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	stuck, err := New(&heartbeatTask{name: "stuck"})
+	assert.NoError(t, err)
+	alive, err := New(&heartbeatTask{name: "alive", beats: true})
+	assert.NoError(t, err)
+	stuckHelper := WithHeartbeat(stuck, 30*time.Millisecond, registry)
+	aliveHelper := WithHeartbeat(alive, 30*time.Millisecond, registry)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	Run(ctx, cancel, stuckHelper)
+	Run(ctx, cancel, aliveHelper)
+	stalled := stuckHelper.(*heartbeatHelper).metrics.stalled
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(stalled.WithLabelValues("stuck")) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, float64(0), testutil.ToFloat64(stalled.WithLabelValues("alive")))
+	cancel()
+	JoinAll(ctx, time.Second, []Helper{stuckHelper, aliveHelper})
+}
@@ -18,6 +18,7 @@ package taskhelper
 import (
 	"context"
 	"fmt"
+	"runtime/debug"
 	"sync"
 	"time"
 
@@ -77,25 +78,56 @@ func NewTick(task interface{}, interval time.Duration) (Helper, error) {
 //
 // We are directly relying on what the library https://pkg.go.dev/github.com/robfig/cron is supporting.
 func NewCron(task interface{}, cronSchedule string) (Helper, error) {
+	return NewCronWithOptions(task, cronSchedule, CronOptions{})
+}
+
+// NewCronWithOptions is like NewCron but allows monitoring the schedule drift and the missed executions, and catching up after them.
+func NewCronWithOptions(task interface{}, cronSchedule string, opts CronOptions) (Helper, error) {
 	sch, err := cron.ParseStandard(cronSchedule)
 	if err != nil {
 		return nil, err
 	}
+	if opts.Misfire != "" && opts.Misfire != MisfireSkip && opts.Misfire != MisfireRunImmediately {
+		return nil, fmt.Errorf("unknown misfire policy %q", opts.Misfire)
+	}
 	isSimpleTask, err := isSimpleTask(task)
 	if err != nil {
 		return nil, err
 	}
+	var metrics *cronMetrics
+	if opts.PrometheusRegisterer != nil {
+		metrics = newCronMetrics(opts.PrometheusRegisterer)
+	}
 	return &cronRunner{
 		schedule:     sch,
 		task:         task,
 		isSimpleTask: isSimpleTask,
 		done:         make(chan struct{}),
+		opts:         opts,
+		metrics:      metrics,
 	}, nil
 }
 
+// PanicHandler is called with the value recovered and the stack trace when the task handled by the Helper panics.
+type PanicHandler func(helper Helper, recovered interface{}, stack []byte)
+
 // Run is executing in a go-routing the Helper that handles a unique task
 func Run(ctx context.Context, cancelFunc context.CancelFunc, t Helper) {
+	RunWithPanicHandler(ctx, cancelFunc, t, nil)
+}
+
+// RunWithPanicHandler is like Run, but the panics of the task are recovered and given to the handler.
+// The handler is responsible for stopping the application if needed. When the handler is nil, the panics are not recovered.
+// Note that only the panics occurring in the go-routine of the task can be recovered, not the ones in the go-routines created by the task.
+func RunWithPanicHandler(ctx context.Context, cancelFunc context.CancelFunc, t Helper, handler PanicHandler) {
 	go func() {
+		if handler != nil {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					handler(t, recovered, debug.Stack())
+				}
+			}()
+		}
 		if err := t.Start(ctx, cancelFunc); err != nil {
 			logrus.WithError(err).Errorf("'%s' ended in error", t.String())
 		}
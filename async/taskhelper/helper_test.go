@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/perses/common/async"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -91,3 +92,50 @@ func TestJoinAll(t *testing.T) {
 	JoinAll(ctx, 30*time.Second, []Helper{t1, t2, t3})
 	assert.True(t, complexTask.counter >= 2)
 }
+
+type loggingTask struct {
+	async.SimpleTask
+	fields logrus.Fields
+}
+
+func (l *loggingTask) String() string {
+	return "logging task"
+}
+
+func (l *loggingTask) Execute(ctx context.Context, _ context.CancelFunc) error {
+	l.fields = async.Logger(ctx).Data
+	return nil
+}
+
+func TestRunner_InjectLogger(t *testing.T) {
+	task := &loggingTask{}
+	helper, err := New(task)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, helper.Start(ctx, cancel))
+	assert.Equal(t, "logging task", task.fields["task"])
+}
+
+func TestRunWithPanicHandler(t *testing.T) {
+	helper, err := New(async.TaskFunc("panicking task", func(_ context.Context) error {
+		panic("boom")
+	}))
+	assert.NoError(t, err)
+	recovered := make(chan interface{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	RunWithPanicHandler(ctx, cancel, helper, func(h Helper, r interface{}, stack []byte) {
+		assert.Equal(t, "panicking task", h.String())
+		assert.NotEmpty(t, stack)
+		recovered <- r
+	})
+	select {
+	case r := <-recovered:
+		assert.Equal(t, "boom", r)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the panic has not been recovered")
+	}
+	// the helper must be considered as done even if the task panicked
+	<-helper.Done()
+}
@@ -0,0 +1,125 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+const (
+	// AgeIdentityEnv is the environment variable containing the age identities (private keys) used to decrypt the config files.
+	AgeIdentityEnv = "AGE_IDENTITY"
+	// AgeIdentityFileEnv is the environment variable containing the path to a file with the age identities used to decrypt the config files.
+	AgeIdentityFileEnv = "AGE_IDENTITY_FILE"
+
+	ageHeader = "age-encryption.org/v1"
+)
+
+// Decrypter is used by the Resolver to decrypt the config files before decoding them.
+// It is called for every file read (including the files included with the directive $include).
+//
+// SOPS is supported by plugging its decryption library. For example:
+//
+//	config.NewResolver[Config]().
+//	  SetDecrypter(config.DecrypterFunc(func(data []byte) ([]byte, error) {
+//	    if !bytes.Contains(data, []byte("sops:")) {
+//	      return data, nil
+//	    }
+//	    return decrypt.Data(data, "yaml")
+//	  }))
+type Decrypter interface {
+	// Decrypt returns the data in clear. If the data is not encrypted, it must be returned untouched.
+	Decrypt(data []byte) ([]byte, error)
+}
+
+// DecrypterFunc is an adapter to allow the use of ordinary functions as Decrypter.
+type DecrypterFunc func(data []byte) ([]byte, error)
+
+func (f DecrypterFunc) Decrypt(data []byte) ([]byte, error) {
+	return f(data)
+}
+
+type ageDecrypter struct {
+	identities []age.Identity
+}
+
+// NewAgeDecrypter returns a Decrypter that decrypts the files encrypted with age (binary or armored).
+// Files that are not encrypted are returned untouched.
+func NewAgeDecrypter(identities ...age.Identity) Decrypter {
+	return &ageDecrypter{identities: identities}
+}
+
+// NewAgeDecrypterFromEnv returns a Decrypter using the age identities provided by the environment variable AgeIdentityEnv
+// or by the file referenced by the environment variable AgeIdentityFileEnv.
+func NewAgeDecrypterFromEnv() (Decrypter, error) {
+	var keys string
+	if identity, ok := os.LookupEnv(AgeIdentityEnv); ok {
+		keys = identity
+	} else if identityFile, ok := os.LookupEnv(AgeIdentityFileEnv); ok {
+		data, err := os.ReadFile(identityFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the age identity file: %w", err)
+		}
+		keys = string(data)
+	} else {
+		return nil, fmt.Errorf("config is encrypted but neither %s nor %s is set", AgeIdentityEnv, AgeIdentityFileEnv)
+	}
+	identities, err := age.ParseIdentities(strings.NewReader(keys))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the age identities: %w", err)
+	}
+	return NewAgeDecrypter(identities...), nil
+}
+
+func (a *ageDecrypter) Decrypt(data []byte) ([]byte, error) {
+	if !isAgeEncrypted(data) {
+		return data, nil
+	}
+	var src io.Reader = bytes.NewReader(data)
+	if !bytes.HasPrefix(data, []byte(ageHeader)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+	r, err := age.Decrypt(src, a.identities...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt the config: %w", err)
+	}
+	return io.ReadAll(r)
+}
+
+func isAgeEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(ageHeader)) || bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header))
+}
+
+// decrypt is using the Decrypter if provided. Otherwise, if the data is encrypted with age,
+// the identities are loaded from the environment.
+func decrypt(decrypter Decrypter, data []byte) ([]byte, error) {
+	if decrypter != nil {
+		return decrypter.Decrypt(data)
+	}
+	if !isAgeEncrypted(data) {
+		return data, nil
+	}
+	d, err := NewAgeDecrypterFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return d.Decrypt(data)
+}
@@ -0,0 +1,89 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/stretchr/testify/assert"
+)
+
+func encryptWithAge(t *testing.T, recipient age.Recipient, data string, armored bool) []byte {
+	buf := &bytes.Buffer{}
+	var dst io.Writer = buf
+	var armorWriter io.WriteCloser
+	if armored {
+		armorWriter = armor.NewWriter(buf)
+		dst = armorWriter
+	}
+	w, err := age.Encrypt(dst, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if armorWriter != nil {
+		if err = armorWriter.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestResolveImpl_AgeEncryptedFile(t *testing.T) {
+	type Config struct {
+		Password string `yaml:"password"`
+		User     string `yaml:"user"`
+	}
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml.age")
+	if err = os.WriteFile(configFile, encryptWithAge(t, identity.Recipient(), "password: secret\n$include: user.yaml\n", false), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, "user.yaml"), encryptWithAge(t, identity.Recipient(), "user: admin\n", true), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var c Config
+	err = NewResolver[Config]().
+		SetConfigFile(configFile).
+		SetDecrypter(NewAgeDecrypter(identity)).
+		Resolve(&c).
+		Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, Config{Password: "secret", User: "admin"}, c)
+
+	t.Setenv(AgeIdentityEnv, identity.String())
+	c = Config{}
+	err = NewResolver[Config]().
+		SetConfigFile(configFile).
+		Resolve(&c).
+		Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, Config{Password: "secret", User: "admin"}, c)
+}
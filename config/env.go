@@ -0,0 +1,429 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nexucis/lamenv"
+)
+
+// EnvTag is the struct tag that can be used to name a field in the environment variables differently from the config file.
+// It has the priority over the tags yaml, json and mapstructure.
+//
+// Example:
+//
+//	type ServerConfig struct {
+//	  ListenAddress string `yaml:"listen_address" env:"addr"`
+//	}
+const EnvTag = "env"
+
+// EnvCasing is the case of the names of the environment variables.
+type EnvCasing int
+
+const (
+	EnvUpperCase EnvCasing = iota
+	EnvLowerCase
+)
+
+// EnvOptions controls how the fields of the config are mapped to the names of the environment variables (see Resolver.SetEnvOptions).
+// By default, the names are in upper case and the prefix and the fields are separated by "_" (e.g. PERSES_SERVER_PORT).
+type EnvOptions struct {
+	// Delimiter separates the prefix and the name of each field. For example with "__", the variable is PERSES__SERVER__PORT.
+	// A delimiter that can't be found in the names of the fields also removes the ambiguity of the keys of the maps.
+	Delimiter string
+	Casing    EnvCasing
+}
+
+func (o EnvOptions) delimiter() string {
+	if len(o.Delimiter) == 0 {
+		return "_"
+	}
+	return o.Delimiter
+}
+
+func (o EnvOptions) isDefault() bool {
+	return o.delimiter() == "_" && o.Casing == EnvUpperCase
+}
+
+// name returns the name of the environment variable matching the parts.
+func (o EnvOptions) name(parts []string) string {
+	return strings.Join(parts, o.delimiter())
+}
+
+func (o EnvOptions) cased(name string) string {
+	if o.Casing == EnvLowerCase {
+		return strings.ToLower(name)
+	}
+	return strings.ToUpper(name)
+}
+
+var (
+	// same list and same order as the one used by lamenv (see decodeEnv)
+	envTagSupports       = []string{EnvTag, "yaml", "json", "mapstructure"}
+	envUnmarshalerType   = reflect.TypeOf((*lamenv.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType         = reflect.TypeOf(time.Duration(0))
+	envUnknownErrorLimit = 10
+)
+
+// decodeEnv decodes the environment variables starting with the prefix in the config.
+// lamenv is used with the default options. Otherwise, the variables are decoded following the same rules as lamenv, but with the naming set in the options
+// (lamenv always builds the names in upper case with the delimiter "_", it cannot be configured).
+func decodeEnv(config interface{}, prefix string, opts EnvOptions) error {
+	if opts.isDefault() {
+		return lamenv.New().OverrideTagSupport(envTagSupports...).Unmarshal(config, []string{prefix})
+	}
+	return newEnvDecoder(opts).decodeWithPrefix(config, prefix)
+}
+
+// unknownEnvVariables returns the environment variables starting with the prefix that don't match any field of the type t.
+// The matching follows the same rules as lamenv.
+func unknownEnvVariables(t reflect.Type, prefix string, opts EnvOptions) []string {
+	if len(prefix) == 0 {
+		// without prefix, every environment variable could be considered, which doesn't make sense.
+		return nil
+	}
+	envPrefix := opts.cased(prefix) + opts.delimiter()
+	var result []string
+	for _, e := range os.Environ() {
+		name, _, _ := strings.Cut(e, "=")
+		if !strings.HasPrefix(name, envPrefix) {
+			continue
+		}
+		if !matchEnv(t, strings.TrimPrefix(name, envPrefix), opts) {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func unknownEnvError(variables []string) error {
+	if len(variables) > envUnknownErrorLimit {
+		return fmt.Errorf("unknown environment variables: %s and %d more", strings.Join(variables[:envUnknownErrorLimit], ", "), len(variables)-envUnknownErrorLimit)
+	}
+	return fmt.Errorf("unknown environment variables: %s", strings.Join(variables, ", "))
+}
+
+// matchEnv returns true if the name (without the prefix) can be decoded in the type t.
+func matchEnv(t reflect.Type, name string, opts EnvOptions) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	ptrType := reflect.PointerTo(t)
+	if ptrType.Implements(envUnmarshalerType) {
+		// the type is decoding itself, we cannot know what it expects.
+		return true
+	}
+	if ptrType.Implements(textUnmarshalerType) {
+		return len(name) == 0
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return matchEnvStruct(t, name, opts)
+	case reflect.Slice, reflect.Array:
+		index, rest, _ := strings.Cut(name, opts.delimiter())
+		if len(index) == 0 || strings.Trim(index, "0123456789") != "" {
+			return false
+		}
+		return matchEnv(t.Elem(), rest, opts)
+	case reflect.Map, reflect.Interface:
+		// the key of the map is guessed by lamenv, so any variable below the current one can be considered.
+		return len(name) > 0
+	default:
+		return len(name) == 0
+	}
+}
+
+func matchEnvStruct(t reflect.Type, name string, opts EnvOptions) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+		fieldName := field.Name
+		if tags, ok := lookupEnvTag(field.Tag); ok {
+			fieldName = tags[0]
+			if fieldName == "-" {
+				continue
+			}
+			if containsStr(tags[1:], "inline") || containsStr(tags[1:], "squash") {
+				if matchEnv(field.Type, name, opts) {
+					return true
+				}
+				continue
+			}
+		}
+		fieldName = opts.cased(fieldName)
+		if name == fieldName {
+			if matchEnv(field.Type, "", opts) {
+				return true
+			}
+			continue
+		}
+		fieldPrefix := fieldName + opts.delimiter()
+		if strings.HasPrefix(name, fieldPrefix) && matchEnv(field.Type, strings.TrimPrefix(name, fieldPrefix), opts) {
+			return true
+		}
+	}
+	return false
+}
+
+func lookupEnvTag(tag reflect.StructTag) ([]string, bool) {
+	for _, tagSupport := range envTagSupports {
+		if s, ok := tag.Lookup(tagSupport); ok {
+			return strings.Split(s, ","), true
+		}
+	}
+	return nil, false
+}
+
+func containsStr(series []string, s string) bool {
+	for _, str := range series {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
+
+// envDecoder decodes the environment variables like lamenv, but with a custom naming (see EnvOptions).
+// The tests check that both give the same result with the same variables.
+type envDecoder struct {
+	opts EnvOptions
+	env  map[string]string
+}
+
+func newEnvDecoder(opts EnvOptions) *envDecoder {
+	d := &envDecoder{opts: opts, env: make(map[string]string)}
+	for _, e := range os.Environ() {
+		name, value, _ := strings.Cut(e, "=")
+		d.env[name] = value
+	}
+	return d
+}
+
+func (d *envDecoder) decodeWithPrefix(config interface{}, prefix string) error {
+	var parts []string
+	if len(prefix) > 0 {
+		parts = []string{d.opts.cased(prefix)}
+	}
+	return d.decode(reflect.ValueOf(config), parts)
+}
+
+func (d *envDecoder) lookup(parts []string) (string, bool) {
+	value, ok := d.env[d.opts.name(parts)]
+	return value, ok
+}
+
+// has returns true if there is at least one environment variable matching the parts or starting with them.
+func (d *envDecoder) has(parts []string) bool {
+	name := d.opts.name(parts)
+	for e := range d.env {
+		if e == name || strings.HasPrefix(e, name+d.opts.delimiter()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *envDecoder) appendPart(parts []string, part string) []string {
+	result := make([]string, len(parts), len(parts)+1)
+	copy(result, parts)
+	return append(result, d.opts.cased(part))
+}
+
+func (d *envDecoder) decode(v reflect.Value, parts []string) error {
+	if v.Kind() == reflect.Ptr {
+		// like lamenv, the pointer is always initialized. The fields with the option omitempty are skipped when no variable matches them (see decodeStruct).
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem(), parts)
+	}
+	if v.CanAddr() {
+		ptr := v.Addr().Interface()
+		if u, ok := ptr.(lamenv.Unmarshaler); ok {
+			return u.UnmarshalEnv(parts)
+		}
+		if u, ok := ptr.(encoding.TextUnmarshaler); ok {
+			if input, exist := d.lookup(parts); exist {
+				return u.UnmarshalText([]byte(input))
+			}
+			return nil
+		}
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return d.decodeStruct(v, parts)
+	case reflect.Slice:
+		return d.decodeSlice(v, parts)
+	case reflect.Map:
+		return d.decodeMap(v, parts)
+	default:
+		if input, exist := d.lookup(parts); exist {
+			return decodeEnvNative(v, input)
+		}
+	}
+	return nil
+}
+
+func (d *envDecoder) decodeStruct(v reflect.Value, parts []string) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+		fieldName := field.Name
+		if tags, ok := lookupEnvTag(field.Tag); ok {
+			if tags[0] == "-" {
+				continue
+			}
+			if containsStr(tags[1:], "inline") || containsStr(tags[1:], "squash") {
+				if err := d.decode(v.Field(i), parts); err != nil {
+					return err
+				}
+				continue
+			}
+			if len(tags[0]) > 0 {
+				fieldName = tags[0]
+			}
+			if containsStr(tags[1:], "omitempty") && !d.has(d.appendPart(parts, fieldName)) {
+				continue
+			}
+		}
+		if err := d.decode(v.Field(i), d.appendPart(parts, fieldName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *envDecoder) decodeSlice(v reflect.Value, parts []string) error {
+	for i := 0; d.has(d.appendPart(parts, strconv.Itoa(i))); i++ {
+		if i >= v.Len() {
+			v.Set(reflect.Append(v, reflect.New(v.Type().Elem()).Elem()))
+		}
+		if err := d.decode(v.Index(i), d.appendPart(parts, strconv.Itoa(i))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeMap guesses the key of the map from the variables starting with the parts: the key is followed by the path of a field of the value (see guessMapKey).
+// Like with lamenv, the keys are always in lower case and the values are decoded from scratch.
+func (d *envDecoder) decodeMap(v reflect.Value, parts []string) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("unable to decode a map with a key that is not a string from the environment")
+	}
+	if v.Type().Elem().Kind() == reflect.Map {
+		return fmt.Errorf("unable to decode a map of a map from the environment, the keys cannot be determined")
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	prefix := d.opts.name(parts) + d.opts.delimiter()
+	keys := make(map[string]bool)
+	for e := range d.env {
+		rest, ok := strings.CutPrefix(e, prefix)
+		if !ok || len(rest) == 0 {
+			continue
+		}
+		key, err := d.guessMapKey(v.Type().Elem(), rest)
+		if err != nil {
+			return err
+		}
+		if len(key) > 0 {
+			keys[key] = true
+		}
+	}
+	for key := range keys {
+		value := reflect.New(v.Type().Elem()).Elem()
+		if err := d.decode(value, d.appendPart(parts, key)); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(strings.ToLower(key)).Convert(v.Type().Key()), value)
+	}
+	return nil
+}
+
+// guessMapKey returns the key of the map in the name (without the prefix) of the variable. The key can contain the delimiter,
+// so every possible key is tried: the rest of the name must then be decodable in the value of the map.
+// It returns an empty key when the variable doesn't match the value, and an error when several keys are possible.
+func (d *envDecoder) guessMapKey(valueType reflect.Type, name string) (string, error) {
+	segments := strings.Split(name, d.opts.delimiter())
+	var result string
+	for i := 1; i <= len(segments); i++ {
+		if !matchEnv(valueType, strings.Join(segments[i:], d.opts.delimiter()), d.opts) {
+			continue
+		}
+		if len(result) > 0 {
+			return "", fmt.Errorf("too many possibilities available when choosing the key of the map in %q", name)
+		}
+		result = strings.Join(segments[:i], d.opts.delimiter())
+	}
+	return result, nil
+}
+
+func decodeEnvNative(v reflect.Value, input string) error {
+	if v.Kind() == reflect.String {
+		v.SetString(input)
+		return nil
+	}
+	input = strings.TrimSpace(input)
+	switch v.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(input)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			duration, err := time.ParseDuration(input)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(duration))
+			return nil
+		}
+		i, err := strconv.ParseInt(input, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(input, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(input, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type parityServer struct {
+	Port          int           `yaml:"port"`
+	ListenAddress string        `yaml:"listen_address" env:"addr"`
+	Timeout       time.Duration `yaml:"timeout"`
+	Ratio         float64       `yaml:"ratio"`
+	Enabled       bool          `yaml:"enabled"`
+}
+
+type parityInline struct {
+	Name string `yaml:"name"`
+}
+
+type parityConfig struct {
+	Inline    parityInline            `yaml:",inline"`
+	Server    parityServer            `yaml:"server"`
+	Endpoints []parityServer          `yaml:"endpoints"`
+	Tags      []string                `yaml:"tags"`
+	Labels    map[string]string       `yaml:"labels"`
+	Backends  map[string]parityServer `yaml:"backends"`
+	TLS       *parityServer           `yaml:"tls"`
+	Optional  *parityServer           `yaml:"optional,omitempty"`
+	Ignored   string                  `yaml:"-"`
+	NoTag     string
+}
+
+// parityEnv contains the parts of the name of each variable, so it can be built with any EnvOptions.
+var parityEnv = []struct {
+	parts []string
+	value string
+}{
+	{parts: []string{"NAME"}, value: "main"},
+	{parts: []string{"SERVER", "PORT"}, value: "9090"},
+	{parts: []string{"SERVER", "ADDR"}, value: "localhost"},
+	{parts: []string{"SERVER", "TIMEOUT"}, value: "10s"},
+	{parts: []string{"SERVER", "RATIO"}, value: "0.5"},
+	{parts: []string{"SERVER", "ENABLED"}, value: "true"},
+	{parts: []string{"ENDPOINTS", "0", "PORT"}, value: "8080"},
+	{parts: []string{"ENDPOINTS", "1", "ADDR"}, value: "remote"},
+	{parts: []string{"TAGS", "0"}, value: "a"},
+	{parts: []string{"TAGS", "1"}, value: "b"},
+	{parts: []string{"LABELS", "MY_ENV"}, value: "prod"},
+	{parts: []string{"BACKENDS", "EU_WEST", "PORT"}, value: "80"},
+	{parts: []string{"BACKENDS", "EU_WEST", "ADDR"}, value: "eu.example.com"},
+	{parts: []string{"BACKENDS", "US", "LISTEN_ADDRESS"}, value: "no effect, the field is renamed"},
+	{parts: []string{"IGNORED"}, value: "no effect, the field is ignored"},
+	{parts: []string{"NOTAG"}, value: "set"},
+}
+
+func setParityEnv(t *testing.T, opts EnvOptions) {
+	for _, e := range parityEnv {
+		name := opts.cased(strings.Join(append([]string{"UT_PARITY"}, e.parts...), opts.delimiter()))
+		t.Setenv(name, e.value)
+	}
+}
+
+// TestEnvDecoder_Parity checks that envDecoder decodes the same variables as lamenv, whatever the naming of the variables.
+func TestEnvDecoder_Parity(t *testing.T) {
+	var expected parityConfig
+	t.Run("lamenv", func(t *testing.T) {
+		setParityEnv(t, EnvOptions{})
+		require.NoError(t, decodeEnv(&expected, "UT_PARITY", EnvOptions{}))
+	})
+	assert.Equal(t, "main", expected.Inline.Name)
+	assert.Equal(t, parityServer{Port: 9090, ListenAddress: "localhost", Timeout: 10 * time.Second, Ratio: 0.5, Enabled: true}, expected.Server)
+	assert.Equal(t, []parityServer{{Port: 8080}, {ListenAddress: "remote"}}, expected.Endpoints)
+	assert.Equal(t, map[string]parityServer{"eu_west": {Port: 80, ListenAddress: "eu.example.com"}}, expected.Backends)
+	assert.NotNil(t, expected.TLS)
+	assert.Nil(t, expected.Optional)
+
+	for _, opts := range []EnvOptions{
+		{},
+		{Delimiter: "__"},
+		{Delimiter: ".", Casing: EnvLowerCase},
+	} {
+		t.Run(opts.delimiter(), func(t *testing.T) {
+			setParityEnv(t, opts)
+			var c parityConfig
+			require.NoError(t, newEnvDecoder(opts).decodeWithPrefix(&c, "UT_PARITY"))
+			assert.Equal(t, expected, c)
+		})
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IncludeKey is the key that can be used in any mapping of the config file to include the content of other files.
+// The value can be a single path or a list of paths. Relative paths are resolved from the directory of the file containing the directive.
+// The content of the included files is merged into the mapping holding the directive.
+// In case of conflict, the keys defined next to the directive always win over the included ones.
+// When the config file is watched (see AddChangeCallback), the included files are watched too.
+//
+// Example:
+//
+//	database:
+//	  $include: database.yaml
+//	  timeout: 10s
+//	$include:
+//	  - server.yaml
+//	  - security.yaml
+const IncludeKey = "$include"
+
+// resolveIncludes is looking for the IncludeKey in the yaml document and replaces it by the content of the included files.
+// filename is the path of the file the data comes from. It is used to resolve the relative paths and to detect cycles.
+// It can be empty if the data doesn't come from a file. In that case, the relative paths are resolved from the working directory.
+// decrypter is used to decrypt the included files (see decrypt).
+// It also returns the absolute path of every file included, so they can be watched along with the config file.
+func resolveIncludes(data []byte, filename string, decrypter Decrypter) ([]byte, []string, error) {
+	if !bytes.Contains(data, []byte(IncludeKey)) {
+		// nothing to include, no need to parse the document twice
+		return data, nil, nil
+	}
+	root, err := parseDocument(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var stack []string
+	if len(filename) > 0 {
+		absFilename, absErr := filepath.Abs(filename)
+		if absErr != nil {
+			return nil, nil, absErr
+		}
+		stack = append(stack, absFilename)
+	}
+	included := make(map[string]struct{})
+	if err := includeRec(root, includeDir(filename), stack, decrypter, included); err != nil {
+		return nil, nil, err
+	}
+	files := make([]string, 0, len(included))
+	for f := range included {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	data, err = yaml.Marshal(root)
+	return data, files, err
+}
+
+func includeDir(filename string) string {
+	if len(filename) == 0 {
+		return "."
+	}
+	return filepath.Dir(filename)
+}
+
+// parseDocument returns the root node of the yaml document (i.e. the content of the DocumentNode)
+func parseDocument(data []byte) (*yaml.Node, error) {
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+	return doc.Content[0], nil
+}
+
+// includeRec walks through the node and resolves every include directive found.
+// stack contains the absolute path of the files currently being included. It is used to detect cycles.
+// included collects the absolute path of every file included.
+func includeRec(node *yaml.Node, dir string, stack []string, decrypter Decrypter, included map[string]struct{}) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		for _, n := range node.Content {
+			if err := includeRec(n, dir, stack, decrypter, included); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		var paths []string
+		var content []*yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value != IncludeKey {
+				if err := includeRec(value, dir, stack, decrypter, included); err != nil {
+					return err
+				}
+				content = append(content, key, value)
+				continue
+			}
+			p, err := includePaths(value)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, p...)
+		}
+		node.Content = content
+		for _, path := range paths {
+			includedNode, err := loadInclude(path, dir, stack, decrypter, included)
+			if err != nil {
+				return err
+			}
+			if includedNode.Kind != yaml.MappingNode {
+				return fmt.Errorf("the file %q included must contain a yaml object", path)
+			}
+			mergeNode(node, includedNode)
+		}
+	}
+	return nil
+}
+
+func includePaths(value *yaml.Node) ([]string, error) {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		return []string{value.Value}, nil
+	case yaml.SequenceNode:
+		var result []string
+		for _, n := range value.Content {
+			if n.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: %s only accepts a path or a list of paths", n.Line, IncludeKey)
+			}
+			result = append(result, n.Value)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("line %d: %s only accepts a path or a list of paths", value.Line, IncludeKey)
+	}
+}
+
+func loadInclude(path string, dir string, stack []string, decrypter Decrypter, included map[string]struct{}) (*yaml.Node, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range stack {
+		if p == absPath {
+			return nil, fmt.Errorf("include cycle detected: %s -> %s", strings.Join(stack, " -> "), absPath)
+		}
+	}
+	included[absPath] = struct{}{}
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to include the file %q: %w", path, err)
+	}
+	data, err = decrypt(decrypter, data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt the included file %q: %w", path, err)
+	}
+	node, err := parseDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the included file %q: %w", path, err)
+	}
+	if err := includeRec(node, filepath.Dir(absPath), append(stack, absPath), decrypter, included); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// mergeNode merges the mapping src into the mapping dst.
+// The keys already present in dst are kept. When both values are mappings, they are merged recursively.
+func mergeNode(dst *yaml.Node, src *yaml.Node) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		existing := lookupNode(dst, key.Value)
+		if existing == nil {
+			dst.Content = append(dst.Content, key, value)
+			continue
+		}
+		if existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+			mergeNode(existing, value)
+		}
+	}
+}
+
+func lookupNode(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
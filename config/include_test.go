@@ -0,0 +1,133 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type includeServerConfig struct {
+	Port    int    `yaml:"port"`
+	Address string `yaml:"address"`
+}
+
+type includeConfig struct {
+	Name   string              `yaml:"name"`
+	Server includeServerConfig `yaml:"server"`
+	Tags   []string            `yaml:"tags"`
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResolveImpl_Include(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": `
+name: main
+$include: common/tags.yaml
+server:
+  $include: common/server.yaml
+  port: 9090
+`,
+		"common/server.yaml": `
+port: 8080
+address: localhost
+`,
+		"common/tags.yaml": `
+name: overridden
+tags: [a, b]
+`,
+	})
+	var c includeConfig
+	err := NewResolver[includeConfig]().
+		SetConfigFile(filepath.Join(dir, "config.yaml")).
+		Resolve(&c).
+		Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, includeConfig{
+		Name: "main",
+		Server: includeServerConfig{
+			Port:    9090,
+			Address: "localhost",
+		},
+		Tags: []string{"a", "b"},
+	}, c)
+}
+
+func TestResolveImpl_IncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": "$include: a.yaml\n",
+		"a.yaml":      "$include: b.yaml\n",
+		"b.yaml":      "$include: config.yaml\n",
+	})
+	var c includeConfig
+	err := NewResolver[includeConfig]().
+		SetConfigFile(filepath.Join(dir, "config.yaml")).
+		Resolve(&c).
+		Verify()
+	assert.ErrorContains(t, err, "include cycle detected")
+}
+
+func TestResolveImpl_WatchInclude(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": "name: main\nserver:\n  $include: server.yaml\n",
+		"server.yaml": "port: 8080\n",
+		"other.yaml":  "port: 9090\n",
+	})
+	var port atomic.Int32
+	var c includeConfig
+	err := NewResolver[includeConfig]().
+		SetConfigFile(filepath.Join(dir, "config.yaml")).
+		AddChangeCallback(func(newConfig *includeConfig) {
+			port.Store(int32(newConfig.Server.Port))
+		}).
+		Resolve(&c).
+		Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, 8080, c.Server.Port)
+
+	// a change of the included file reloads the config
+	writeFiles(t, dir, map[string]string{"server.yaml": "port: 8081\n"})
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(8081), port.Load())
+
+	// the file newly included is watched, the one not included anymore is not
+	writeFiles(t, dir, map[string]string{"config.yaml": "name: main\nserver:\n  $include: other.yaml\n"})
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(9090), port.Load())
+	writeFiles(t, dir, map[string]string{"other.yaml": "port: 9091\n"})
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(9091), port.Load())
+	writeFiles(t, dir, map[string]string{"server.yaml": "port: 8082\n"})
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(9091), port.Load())
+}
@@ -0,0 +1,300 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KindKey is the key of the object giving the implementation to use when decoding a field typed as an interface (see RegisterKind).
+const KindKey = "kind"
+
+// RegisterKind registers the implementation of the interface I to use when the key KindKey of the object is equal to kind.
+// It allows a field of the config typed as an interface to be decoded according to the config file,
+// which is useful to select a backend purely by configuration. The factory must return a new instance, ideally a pointer,
+// so the method Verify of the implementation can be called. Like the other registrations, it's usually done in an init function.
+// It panics if I is not an interface or if the kind is already registered for I.
+//
+// Example:
+//
+//	type StorageConfig interface {
+//	  isStorage()
+//	}
+//
+//	func init() {
+//	  config.RegisterKind[StorageConfig]("file", func() StorageConfig { return &FileStorageConfig{} })
+//	  config.RegisterKind[StorageConfig]("sql", func() StorageConfig { return &SQLStorageConfig{} })
+//	}
+//
+//	type Config struct {
+//	  Storage StorageConfig `yaml:"storage"`
+//	}
+//
+// Then the storage is selected with:
+//
+//	storage:
+//	  kind: sql
+//	  dsn: postgres://localhost:5432
+func RegisterKind[I any](kind string, factory func() I) {
+	t := reflect.TypeOf((*I)(nil)).Elem()
+	if t.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("config: unable to register the kind %q, %s is not an interface", kind, t))
+	}
+	kinds.register(t, kind, func() interface{} { return factory() })
+}
+
+var kinds = &kindRegistry{factories: make(map[reflect.Type]map[string]func() interface{})}
+
+type kindRegistry struct {
+	mutex     sync.RWMutex
+	factories map[reflect.Type]map[string]func() interface{}
+}
+
+func (r *kindRegistry) register(t reflect.Type, kind string, factory func() interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.factories[t]; !ok {
+		r.factories[t] = make(map[string]func() interface{})
+	}
+	if _, ok := r.factories[t][kind]; ok {
+		panic(fmt.Sprintf("config: the kind %q is already registered for %s", kind, t))
+	}
+	r.factories[t][kind] = factory
+}
+
+func (r *kindRegistry) isEmpty() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return len(r.factories) == 0
+}
+
+func (r *kindRegistry) has(t reflect.Type) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	_, ok := r.factories[t]
+	return ok
+}
+
+func (r *kindRegistry) lookup(t reflect.Type, kind string) (func() interface{}, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if factory, ok := r.factories[t][kind]; ok {
+		return factory, nil
+	}
+	known := make([]string, 0, len(r.factories[t]))
+	for k := range r.factories[t] {
+		known = append(known, k)
+	}
+	sort.Strings(known)
+	return nil, fmt.Errorf("unknown kind %q for %s, the known kinds are: %s", kind, t, strings.Join(known, ", "))
+}
+
+// decodeDocument decodes the yaml document in out, including the fields typed as an interface registered with RegisterKind.
+// These fields are replaced by null before decoding the document, as the yaml decoder doesn't support them.
+// They are then decoded separately, once their implementation is known.
+func decodeDocument(data []byte, out interface{}, strict bool) error {
+	if !kinds.isEmpty() {
+		root, err := parseDocument(data)
+		if err != nil {
+			return err
+		}
+		d := &kindDecoder{strict: strict, placeholders: make(map[*yaml.Node]yaml.Node)}
+		d.strip(root, reflect.TypeOf(out))
+		if len(d.placeholders) > 0 {
+			if data, err = yaml.Marshal(root); err != nil {
+				return err
+			}
+			if err := decodeStrict(data, out, strict); err != nil {
+				return err
+			}
+			return d.fill(root, reflect.ValueOf(out))
+		}
+	}
+	return decodeStrict(data, out, strict)
+}
+
+func decodeStrict(data []byte, out interface{}, strict bool) error {
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	d.KnownFields(strict)
+	return d.Decode(out)
+}
+
+type kindDecoder struct {
+	strict bool
+	// placeholders contains the original content of the nodes replaced by null.
+	placeholders map[*yaml.Node]yaml.Node
+}
+
+// strip replaces by null the nodes matching an interface registered with RegisterKind.
+func (d *kindDecoder) strip(node *yaml.Node, t reflect.Type) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		d.strip(node, t.Elem())
+	case reflect.Interface:
+		if !kinds.has(t) || node.Kind != yaml.MappingNode {
+			// the decoder will fail by itself if the node is not an object, unless it's null
+			return
+		}
+		d.placeholders[node] = *node
+		*node = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case reflect.Struct:
+		forEachField(node, t, func(fieldNode *yaml.Node, i int) {
+			d.strip(fieldNode, t.Field(i).Type)
+		})
+	case reflect.Slice, reflect.Array:
+		if node.Kind == yaml.SequenceNode {
+			for _, n := range node.Content {
+				d.strip(n, t.Elem())
+			}
+		}
+	case reflect.Map:
+		if node.Kind == yaml.MappingNode {
+			for i := 1; i < len(node.Content); i += 2 {
+				d.strip(node.Content[i], t.Elem())
+			}
+		}
+	}
+}
+
+// fill decodes the nodes replaced by strip in the value v, once the rest of the document has been decoded.
+func (d *kindDecoder) fill(node *yaml.Node, v reflect.Value) error {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return d.fill(node, v.Elem())
+	case reflect.Interface:
+		original, ok := d.placeholders[node]
+		if !ok {
+			return nil
+		}
+		impl, err := d.decodeKind(&original, v.Type())
+		if err != nil {
+			return err
+		}
+		v.Set(impl)
+	case reflect.Struct:
+		var err error
+		forEachField(node, v.Type(), func(fieldNode *yaml.Node, i int) {
+			if err == nil {
+				err = d.fill(fieldNode, v.Field(i))
+			}
+		})
+		return err
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i := 0; i < len(node.Content) && i < v.Len(); i++ {
+			if err := d.fill(node.Content[i], v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode || v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := reflect.ValueOf(node.Content[i].Value).Convert(v.Type().Key())
+			existing := v.MapIndex(key)
+			if !existing.IsValid() {
+				continue
+			}
+			// the values of a map are not addressable, so a copy is filled and then put back in the map
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(existing)
+			if err := d.fill(node.Content[i+1], value); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, value)
+		}
+	}
+	return nil
+}
+
+func (d *kindDecoder) decodeKind(node *yaml.Node, t reflect.Type) (reflect.Value, error) {
+	kindNode := lookupNode(node, KindKey)
+	if kindNode == nil {
+		return reflect.Value{}, fmt.Errorf("line %d: the key %q is required to decode %s", node.Line, KindKey, t)
+	}
+	factory, err := kinds.lookup(t, kindNode.Value)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("line %d: %w", kindNode.Line, err)
+	}
+	// the key KindKey is removed, so the implementation doesn't have to declare it when the decoding is strict.
+	content := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != KindKey {
+			content.Content = append(content.Content, node.Content[i], node.Content[i+1])
+		}
+	}
+	data, err := yaml.Marshal(content)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	impl := reflect.ValueOf(factory())
+	target := impl
+	if impl.Kind() != reflect.Ptr {
+		target = reflect.New(impl.Type())
+		target.Elem().Set(impl)
+	}
+	if err := decodeDocument(data, target.Interface(), d.strict); err != nil {
+		return reflect.Value{}, fmt.Errorf("line %d: unable to decode the %s of kind %q: %w", node.Line, t, kindNode.Value, err)
+	}
+	if impl.Kind() != reflect.Ptr {
+		return target.Elem(), nil
+	}
+	return target, nil
+}
+
+// forEachField calls f with the node of each field of the struct t that is present in the mapping.
+func forEachField(node *yaml.Node, t reflect.Type, f func(fieldNode *yaml.Node, i int)) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if containsStr(strings.Split(options, ","), "inline") {
+			f(node, i)
+			continue
+		}
+		if len(name) == 0 {
+			name = strings.ToLower(field.Name)
+		}
+		if fieldNode := lookupNode(node, name); fieldNode != nil {
+			f(fieldNode, i)
+		}
+	}
+}
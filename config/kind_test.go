@@ -0,0 +1,134 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type storageConfig interface {
+	isStorage()
+}
+
+type fileStorageConfig struct {
+	Folder string `yaml:"folder"`
+}
+
+func (f *fileStorageConfig) isStorage() {}
+
+func (f *fileStorageConfig) Verify() error {
+	if len(f.Folder) == 0 {
+		f.Folder = "./data"
+	}
+	return nil
+}
+
+type sqlStorageConfig struct {
+	DSN string `yaml:"dsn"`
+}
+
+func (s *sqlStorageConfig) isStorage() {}
+
+func init() {
+	RegisterKind[storageConfig]("file", func() storageConfig { return &fileStorageConfig{} })
+	RegisterKind[storageConfig]("sql", func() storageConfig { return &sqlStorageConfig{} })
+}
+
+func TestResolveImpl_Kind(t *testing.T) {
+	type Config struct {
+		Name    string                   `yaml:"name"`
+		Storage storageConfig            `yaml:"storage"`
+		Backups []storageConfig          `yaml:"backups"`
+		Mirrors map[string]storageConfig `yaml:"mirrors"`
+		Cache   *struct {
+			Storage storageConfig `yaml:"storage"`
+		} `yaml:"cache"`
+		Optional storageConfig `yaml:"optional"`
+	}
+	testSuites := []struct {
+		title  string
+		data   string
+		result Config
+		err    string
+	}{
+		{
+			title: "every kind of field",
+			data: `
+name: main
+storage:
+  kind: sql
+  dsn: postgres://localhost:5432
+backups:
+  - kind: file
+    folder: /backup
+  - kind: file
+mirrors:
+  eu:
+    kind: sql
+    dsn: postgres://eu:5432
+cache:
+  storage:
+    kind: file
+    folder: /cache
+`,
+			result: Config{
+				Name:    "main",
+				Storage: &sqlStorageConfig{DSN: "postgres://localhost:5432"},
+				Backups: []storageConfig{&fileStorageConfig{Folder: "/backup"}, &fileStorageConfig{Folder: "./data"}},
+				Mirrors: map[string]storageConfig{"eu": &sqlStorageConfig{DSN: "postgres://eu:5432"}},
+				Cache: &struct {
+					Storage storageConfig `yaml:"storage"`
+				}{Storage: &fileStorageConfig{Folder: "/cache"}},
+			},
+		},
+		{
+			title: "unknown kind",
+			data:  "storage:\n  kind: etcd\n",
+			err:   `line 2: unknown kind "etcd" for config.storageConfig, the known kinds are: file, sql`,
+		},
+		{
+			title: "missing kind",
+			data:  "storage:\n  dsn: postgres://localhost:5432\n",
+			err:   `line 2: the key "kind" is required to decode config.storageConfig`,
+		},
+		{
+			title: "strict decoding of the implementation",
+			data:  "storage:\n  kind: file\n  dsn: postgres://localhost:5432\n",
+			err:   "line 2: unable to decode the config.storageConfig of kind \"file\": yaml: unmarshal errors:\n  line 1: field dsn not found in type config.fileStorageConfig",
+		},
+	}
+	for _, test := range testSuites {
+		t.Run(test.title, func(t *testing.T) {
+			var c Config
+			err := NewResolver[Config]().SetConfigData([]byte(test.data)).Resolve(&c).Verify()
+			if len(test.err) > 0 {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.result, c)
+		})
+	}
+}
+
+func TestRegisterKind(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterKind[storageConfig]("file", func() storageConfig { return &fileStorageConfig{} })
+	})
+	assert.Panics(t, func() {
+		RegisterKind[fileStorageConfig]("file", func() fileStorageConfig { return fileStorageConfig{} })
+	})
+}
@@ -0,0 +1,67 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"github.com/perses/common/promhelper"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	reloadSuccess = "success"
+	reloadFailure = "failure"
+)
+
+type metrics struct {
+	warnings             prometheus.Gauge
+	lastReloadSuccessful prometheus.Gauge
+	lastReloadTimestamp  prometheus.Gauge
+	reloads              *prometheus.CounterVec
+}
+
+func newMetrics(r prometheus.Registerer) *metrics {
+	return &metrics{
+		warnings: promhelper.RegisterOrReuseOrLog(r, prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "config_verify_warnings",
+			Help: "Number of warnings returned by the last verification of the config",
+		})),
+		lastReloadSuccessful: promhelper.RegisterOrReuseOrLog(r, prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "config_last_reload_successful",
+			Help: "Whether the last attempt to load the config was successful",
+		})),
+		lastReloadTimestamp: promhelper.RegisterOrReuseOrLog(r, prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "config_last_reload_timestamp_seconds",
+			Help: "Timestamp of the last successful load of the config",
+		})),
+		reloads: promhelper.RegisterOrReuseOrLog(r, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "config_reloads_total",
+			Help: "Total of attempts to load the config",
+		}, []string{"result"})),
+	}
+}
+
+// observeReload records the result of an attempt to load the config. m can be nil when no registerer has been given to the Resolver.
+func (m *metrics) observeReload(err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.lastReloadSuccessful.Set(0)
+		m.reloads.WithLabelValues(reloadFailure).Inc()
+		return
+	}
+	m.lastReloadSuccessful.Set(1)
+	m.lastReloadTimestamp.SetToCurrentTime()
+	m.reloads.WithLabelValues(reloadSuccess).Inc()
+}
@@ -0,0 +1,180 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// VersionKey is the key at the root of the config file that contains the version of the config schema.
+// When the key is absent, the config is considered to be in the version 1.
+const VersionKey = "config_version"
+
+// MigrationFunc transforms the raw config from one version of the schema to the next one.
+// doc is the root mapping of the yaml document, so the order of the keys and the comments are preserved.
+type MigrationFunc func(doc *yaml.Node) error
+
+// migrate applies in order the migrations starting from the version found in the data. The migration at the index i migrates from the version i+1.
+// It returns the data untouched if there is no migration to apply.
+// When the config doesn't model the key VersionKey (see modelsKey), the key is removed, so the config can still be strictly decoded.
+// A version newer than the one produced by the last migration is rejected, as the config has been written for a more recent schema.
+func migrate(data []byte, migrations []MigrationFunc, keepVersion bool) ([]byte, error) {
+	if len(migrations) == 0 {
+		return data, nil
+	}
+	root, err := parseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("the config must be a yaml object to be migrated")
+	}
+	version := 1
+	versionNode := lookupNode(root, VersionKey)
+	if versionNode != nil {
+		i, convErr := strconv.Atoi(versionNode.Value)
+		if versionNode.Kind != yaml.ScalarNode || convErr != nil {
+			return nil, fmt.Errorf("%s must be an integer", VersionKey)
+		}
+		version = i
+	}
+	latestVersion := len(migrations) + 1
+	if version < 1 || version > latestVersion {
+		return nil, fmt.Errorf("the config version %d is not supported, the versions go from 1 to %d", version, latestVersion)
+	}
+	migrated := version < latestVersion
+	for ; version < latestVersion; version++ {
+		logrus.Debugf("migrating the config from the version %d to %d", version, version+1)
+		if err := migrations[version-1](root); err != nil {
+			return nil, fmt.Errorf("unable to migrate the config from the version %d to %d: %w", version, version+1, err)
+		}
+	}
+	if keepVersion {
+		if !migrated {
+			return data, nil
+		}
+		setNode(root, VersionKey, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(version)})
+	} else {
+		if !migrated && versionNode == nil {
+			return data, nil
+		}
+		removeNode(root, VersionKey)
+	}
+	return yaml.Marshal(root)
+}
+
+// modelsKey returns true if the config of the type t has a field for the given key at its root (or in an inlined struct).
+// It is used to remove the keys handled by the resolver (like VersionKey) when the config doesn't model them, so it can still be strictly decoded.
+func modelsKey(t reflect.Type, key string) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Map {
+		return true
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if containsStr(strings.Split(options, ","), "inline") {
+			if modelsKey(field.Type, key) {
+				return true
+			}
+			continue
+		}
+		if name == key {
+			return true
+		}
+	}
+	return false
+}
+
+// MoveField moves the value at the path "from" to the path "to". Each segment of the path is separated by a dot.
+// It does nothing if there is no value at the path "from". It can be used in a MigrationFunc to rename a field.
+//
+// Example:
+//
+//	config.NewResolver[Config]().
+//	  AddMigration(1, func(doc *yaml.Node) error {
+//	    return config.MoveField(doc, "database.host", "database.address")
+//	  })
+func MoveField(doc *yaml.Node, from string, to string) error {
+	fromPath := strings.Split(from, ".")
+	parent := lookupMapping(doc, fromPath[:len(fromPath)-1], false)
+	if parent == nil {
+		return nil
+	}
+	key, value := removeNode(parent, fromPath[len(fromPath)-1])
+	if value == nil {
+		return nil
+	}
+	toPath := strings.Split(to, ".")
+	newParent := lookupMapping(doc, toPath[:len(toPath)-1], true)
+	if newParent == nil {
+		return fmt.Errorf("unable to move %q to %q, one of the parent is not an object", from, to)
+	}
+	// the node of the key is kept to preserve its comments
+	key.Value = toPath[len(toPath)-1]
+	if lookupNode(newParent, key.Value) != nil {
+		setNode(newParent, key.Value, value)
+	} else {
+		newParent.Content = append(newParent.Content, key, value)
+	}
+	return nil
+}
+
+func lookupMapping(doc *yaml.Node, path []string, create bool) *yaml.Node {
+	if doc.Kind != yaml.MappingNode {
+		return nil
+	}
+	current := doc
+	for _, p := range path {
+		next := lookupNode(current, p)
+		if next == nil {
+			if !create {
+				return nil
+			}
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			current.Content = append(current.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: p}, next)
+		}
+		if next.Kind != yaml.MappingNode {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+// removeNode removes the key from the mapping. It returns the nodes of the key and of the value removed, or nil if the key doesn't exist.
+func removeNode(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			keyNode, valueNode := mapping.Content[i], mapping.Content[i+1]
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return keyNode, valueNode
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,46 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrate_PreserveDocument(t *testing.T) {
+	data := []byte(`# the server
+server:
+  # where to listen
+  host: localhost
+  port: 8080
+database: postgres
+`)
+	migrations := []MigrationFunc{
+		func(doc *yaml.Node) error {
+			return MoveField(doc, "server.host", "server.address")
+		},
+	}
+	result, err := migrate(data, migrations, true)
+	assert.NoError(t, err)
+	assert.Equal(t, `# the server
+server:
+    port: 8080
+    # where to listen
+    address: localhost
+database: postgres
+config_version: 2
+`, string(result))
+}
@@ -0,0 +1,138 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Overrides is a flag.Value collecting a list of key/value overrides like "server.port=9090".
+// It can be registered with RegisterOverrideFlag and then passed to Resolver.AddOverride.
+type Overrides []string
+
+func (o *Overrides) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *Overrides) Set(value string) error {
+	if _, _, err := parseOverride(value); err != nil {
+		return err
+	}
+	*o = append(*o, value)
+	return nil
+}
+
+// RegisterOverrideFlag registers the flag "--set" in the given flag.FlagSet (or in flag.CommandLine if nil).
+// The flag can be repeated to override multiple values.
+//
+// Example:
+//
+//	var overrides config.Overrides
+//	config.RegisterOverrideFlag(nil, &overrides)
+//	flag.Parse()
+//	config.NewResolver[Config]().
+//	  SetConfigFile(configFile).
+//	  AddOverride(overrides...).
+//	  Resolve(&c)
+func RegisterOverrideFlag(fs *flag.FlagSet, overrides *Overrides) {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	fs.Var(overrides, "set", "Override a value of the configuration (can be repeated). The key is the path to the field using the yaml name, like server.port=9090")
+}
+
+// parseOverride splits the override "a.b.c=value" into the path ["a", "b", "c"] and the value.
+func parseOverride(override string) ([]string, string, error) {
+	key, value, ok := strings.Cut(override, "=")
+	if !ok {
+		return nil, "", fmt.Errorf("invalid override %q, it must follow the format key=value", override)
+	}
+	key = strings.TrimSpace(key)
+	if len(key) == 0 {
+		return nil, "", fmt.Errorf("invalid override %q, the key cannot be empty", override)
+	}
+	path := strings.Split(key, ".")
+	for _, p := range path {
+		if len(p) == 0 {
+			return nil, "", fmt.Errorf("invalid override %q, the key contains an empty segment", override)
+		}
+	}
+	return path, value, nil
+}
+
+// buildOverrideDocument transforms the list of overrides into a single yaml document.
+// The value of each override is parsed as yaml so lists, numbers or booleans are supported (e.g. "tags=[a, b]").
+func buildOverrideDocument(overrides []string) ([]byte, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, override := range overrides {
+		path, value, err := parseOverride(override)
+		if err != nil {
+			return nil, err
+		}
+		valueNode := &yaml.Node{}
+		if err := yaml.Unmarshal([]byte(value), valueNode); err != nil {
+			return nil, fmt.Errorf("unable to parse the value of the override %q: %w", override, err)
+		}
+		if valueNode.Kind == yaml.DocumentNode && len(valueNode.Content) > 0 {
+			valueNode = valueNode.Content[0]
+		} else {
+			// empty value
+			valueNode = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: ""}
+		}
+		node := root
+		for _, p := range path[:len(path)-1] {
+			child := lookupNode(node, p)
+			if child == nil || child.Kind != yaml.MappingNode {
+				child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				setNode(node, p, child)
+			}
+			node = child
+		}
+		setNode(node, path[len(path)-1], valueNode)
+	}
+	return yaml.Marshal(root)
+}
+
+func setNode(mapping *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1] = value
+			return
+		}
+	}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// applyOverrides decodes the overrides on top of the given config.
+// As the yaml decoder only sets the fields present in the document, the rest of the config is untouched.
+func applyOverrides(config interface{}, overrides []string, strict bool) error {
+	if len(overrides) == 0 {
+		return nil
+	}
+	data, err := buildOverrideDocument(overrides)
+	if err != nil {
+		return err
+	}
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	d.KnownFields(strict)
+	if err := d.Decode(config); err != nil {
+		return fmt.Errorf("unable to apply the overrides: %w", err)
+	}
+	return nil
+}
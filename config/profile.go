@@ -0,0 +1,102 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProfilesKey is the key at the root of the config file containing the sections specific to each profile (see Resolver.SetProfile).
+// The section of the active profile is merged onto the rest of the config: its values win, and the objects are merged recursively.
+// The sections of the other profiles are ignored.
+//
+// Example:
+//
+//	database:
+//	  address: localhost:5432
+//	  timeout: 10s
+//	profiles:
+//	  prod:
+//	    database:
+//	      address: db.prod:5432
+const ProfilesKey = "profiles"
+
+// applyProfile merges the section of the profile onto the rest of the document and removes the key ProfilesKey.
+// It returns an error if the profile is not defined in the document.
+// When no profile is active and keepProfiles is true (i.e. the config has a field for the key ProfilesKey), the document is left untouched.
+func applyProfile(data []byte, profile string, keepProfiles bool) ([]byte, error) {
+	if len(profile) == 0 && (keepProfiles || !bytes.Contains(data, []byte(ProfilesKey))) {
+		return data, nil
+	}
+	root, err := parseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	if root.Kind != yaml.MappingNode {
+		return data, nil
+	}
+	var profiles *yaml.Node
+	var content []*yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == ProfilesKey {
+			profiles = root.Content[i+1]
+			continue
+		}
+		content = append(content, root.Content[i], root.Content[i+1])
+	}
+	if profiles == nil && len(profile) == 0 {
+		return data, nil
+	}
+	root.Content = content
+	if len(profile) > 0 {
+		var section *yaml.Node
+		if profiles != nil && profiles.Kind == yaml.MappingNode {
+			section = lookupNode(profiles, profile)
+		}
+		if section == nil {
+			return nil, fmt.Errorf("the profile %q is not defined in the config", profile)
+		}
+		if section.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("the profile %q must be a yaml object", profile)
+		}
+		overrideNode(root, section)
+	}
+	return yaml.Marshal(root)
+}
+
+// overrideNode merges the mapping src into the mapping dst. The values of src win, except when both values are mappings: they are then merged recursively.
+func overrideNode(dst *yaml.Node, src *yaml.Node) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		found := false
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value != key.Value {
+				continue
+			}
+			found = true
+			if dst.Content[j+1].Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+				overrideNode(dst.Content[j+1], value)
+			} else {
+				dst.Content[j+1] = value
+			}
+			break
+		}
+		if !found {
+			dst.Content = append(dst.Content, key, value)
+		}
+	}
+}
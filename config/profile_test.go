@@ -0,0 +1,117 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const profileConfigData = `
+name: main
+server:
+  port: 8080
+  address: localhost
+tags: [a, b]
+profiles:
+  prod:
+    server:
+      address: prod.example.com
+    tags: [c]
+  dev:
+    name: dev
+`
+
+func TestResolveImpl_Profile(t *testing.T) {
+	testSuites := []struct {
+		title    string
+		profile  string
+		data     string
+		expected includeConfig
+		isErr    bool
+	}{
+		{
+			title: "no profile",
+			data:  profileConfigData,
+			expected: includeConfig{
+				Name:   "main",
+				Server: includeServerConfig{Port: 8080, Address: "localhost"},
+				Tags:   []string{"a", "b"},
+			},
+		},
+		{
+			title:   "prod profile",
+			profile: "prod",
+			data:    profileConfigData,
+			expected: includeConfig{
+				Name:   "main",
+				Server: includeServerConfig{Port: 8080, Address: "prod.example.com"},
+				Tags:   []string{"c"},
+			},
+		},
+		{
+			title:   "dev profile",
+			profile: "dev",
+			data:    profileConfigData,
+			expected: includeConfig{
+				Name:   "dev",
+				Server: includeServerConfig{Port: 8080, Address: "localhost"},
+				Tags:   []string{"a", "b"},
+			},
+		},
+		{
+			title:   "unknown profile",
+			profile: "staging",
+			data:    profileConfigData,
+			isErr:   true,
+		},
+		{
+			title:   "profile without profiles",
+			profile: "prod",
+			data:    "name: main\n",
+			isErr:   true,
+		},
+	}
+	for _, test := range testSuites {
+		t.Run(test.title, func(t *testing.T) {
+			var c includeConfig
+			err := NewResolver[includeConfig]().
+				SetConfigData([]byte(test.data)).
+				SetProfile(test.profile).
+				Resolve(&c).
+				Verify()
+			if test.isErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, c)
+		})
+	}
+}
+
+func TestResolveImpl_ProfilesField(t *testing.T) {
+	type Config struct {
+		Name     string   `yaml:"name"`
+		Profiles []string `yaml:"profiles"`
+	}
+	var c Config
+	err := NewResolver[Config]().
+		SetConfigData([]byte("name: main\nprofiles: [admin, viewer]\n")).
+		Resolve(&c).
+		Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, Config{Name: "main", Profiles: []string{"admin", "viewer"}}, c)
+}
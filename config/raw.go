@@ -0,0 +1,89 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RawConfig is the document of the config before being decoded, as returned by Resolver.Raw.
+// It lets the components read the sections of the file that the config doesn't model, like the config of the plugins.
+// The environment variables and the overrides are not part of it.
+//
+// Example:
+//
+//	var pluginConfig PluginConfig
+//	if err := resolver.Raw().Decode("plugins.my_plugin", &pluginConfig); err != nil {
+//	  return err
+//	}
+type RawConfig struct {
+	root *yaml.Node
+	// includes is the absolute path of the files included in the config (see IncludeKey).
+	includes []string
+}
+
+func newRawConfig(data []byte) (*RawConfig, error) {
+	root, err := parseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	return &RawConfig{root: root}, nil
+}
+
+// Has returns true if there is a value at the given path. Each segment of the path is separated by a dot.
+func (r *RawConfig) Has(path string) bool {
+	return r.lookup(path) != nil
+}
+
+// Decode decodes the value at the given path in out. Each segment of the path is separated by a dot, an empty path means the whole document.
+// out is left untouched if there is no value at the path, so the default values can be set before.
+// The decoding is strict: it fails if a field of the document doesn't exist in out.
+// It is then possible to use the method Verify (see Validator) on out.
+func (r *RawConfig) Decode(path string, out interface{}) error {
+	node := r.lookup(path)
+	if node == nil {
+		return nil
+	}
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	if err := decodeDocument(data, out, true); err != nil {
+		return fmt.Errorf("unable to decode the raw config %q: %w", path, err)
+	}
+	return nil
+}
+
+func (r *RawConfig) lookup(path string) *yaml.Node {
+	if r == nil || r.root == nil {
+		return nil
+	}
+	node := r.root
+	if len(path) == 0 {
+		return node
+	}
+	for _, p := range strings.Split(path, ".") {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		node = lookupNode(node, p)
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
@@ -0,0 +1,73 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const rawConfigData = `
+name: main
+plugins:
+  my_plugin:
+    url: http://localhost:8080
+    retries: 3
+profiles:
+  prod:
+    plugins:
+      my_plugin:
+        url: https://plugin.prod
+`
+
+type pluginConfig struct {
+	URL     string `yaml:"url"`
+	Retries int    `yaml:"retries"`
+	Timeout string `yaml:"timeout"`
+}
+
+func TestResolveImpl_Raw(t *testing.T) {
+	type Config struct {
+		Name string `yaml:"name"`
+	}
+	var c Config
+	resolver := NewResolver[Config]().
+		Strict(false).
+		SetConfigData([]byte(rawConfigData)).
+		SetProfile("prod")
+	assert.Nil(t, resolver.Raw())
+	if err := resolver.Resolve(&c).Verify(); err != nil {
+		t.Fatal(err)
+	}
+	raw := resolver.Raw()
+	assert.True(t, raw.Has("plugins.my_plugin.url"))
+	assert.False(t, raw.Has("profiles"))
+	assert.False(t, raw.Has("name.unknown"))
+
+	plugin := pluginConfig{Timeout: "10s"}
+	assert.NoError(t, raw.Decode("plugins.my_plugin", &plugin))
+	assert.Equal(t, pluginConfig{URL: "https://plugin.prod", Retries: 3, Timeout: "10s"}, plugin)
+
+	// missing section, the default values are kept
+	other := pluginConfig{Timeout: "10s"}
+	assert.NoError(t, raw.Decode("plugins.other", &other))
+	assert.Equal(t, pluginConfig{Timeout: "10s"}, other)
+
+	// the decoding is strict
+	var strict struct {
+		URL string `yaml:"url"`
+	}
+	assert.Error(t, raw.Decode("plugins.my_plugin", &strict))
+}
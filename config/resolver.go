@@ -23,6 +23,11 @@
 //  1. A good practice is to prefix your environment variable by the name of your application.
 //  2. The config file is not mandatory, you can manage all you configuration using the environment variable.
 //  3. The config by environment is always overriding the config by file.
+//  4. The config file can be split into multiple files using the directive `$include` (see IncludeKey).
+//  5. The config file can be encrypted with age (or SOPS, see Decrypter). It is then decrypted when read.
+//  6. The config file can contain sections specific to an environment that are activated with a profile (see ProfilesKey).
+//  7. The config is reloaded when the file changes (see AddChangeCallback) or on demand (see Reloader).
+//  8. A field typed as an interface is decoded with the implementation selected by the key kind (see RegisterKind).
 //
 // The Resolver at the end returns an object that implements the interface Validator.
 // Each config/struct can implement this interface in order to provide a single way to verify the configuration and to set the default value.
@@ -57,72 +62,105 @@
 package config
 
 import (
-	"bytes"
 	"crypto/sha1"
+	"errors"
+	"fmt"
 	"os"
 	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/nexucis/lamenv"
 	"github.com/perses/common/file"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
+// watchDebounce is the time to wait after the last change on the config file before reading it.
+// It avoids reading a file partially written.
+const watchDebounce = 20 * time.Millisecond
+
 type Validator interface {
 	Verify() error
 }
 
 type validatorImpl struct {
 	Validator
-	err    error
-	config interface{}
+	err      error
+	config   interface{}
+	warnings []error
+	// warningsGauge is optional and set only when a prometheus.Registerer is given to the Resolver
+	warningsGauge prometheus.Gauge
 }
 
 // Verify will check if the different attribute of the config is implementing the interface Validator.
 // If it's the case, then it will call the method Verify of each attribute.
+// The warnings returned by the different methods Verify are logged and can be retrieved with the function Warnings.
 func (v *validatorImpl) Verify() error {
 	if v.err != nil {
 		return v.err
 	}
+	v.warnings = nil
 	ifv := reflect.ValueOf(v.config)
-	return verifyRec(ifv)
+	err := verifyRec(ifv, &v.warnings)
+	for _, w := range v.warnings {
+		logrus.Warnf("config: %s", w)
+	}
+	if v.warningsGauge != nil {
+		v.warningsGauge.Set(float64(len(v.warnings)))
+	}
+	return err
 }
 
-func checkPointer(ptr reflect.Value) error {
+func checkPointer(ptr reflect.Value, warnings *[]error) error {
 	if ptr.IsNil() {
 		return nil
 	}
 	if p, ok := ptr.Interface().(Validator); ok {
 		if err := p.Verify(); err != nil {
-			return err
+			w, hardErrors := splitWarnings(err)
+			if len(w) == 0 {
+				return err
+			}
+			*warnings = append(*warnings, w...)
+			if len(hardErrors) > 0 {
+				return errors.Join(hardErrors...)
+			}
 		}
 	}
 	return nil
 }
 
-func verifyRec(conf reflect.Value) error {
+func verifyRec(conf reflect.Value, warnings *[]error) error {
 	v := conf
 	if conf.Kind() != reflect.Ptr {
 		// that means it's not a pointer, so we have to create one to be able to then know if it implements the interface Validator
 		ptr := reflect.New(v.Type())
 		ptr.Elem().Set(v)
 		// so now we are able to check if the pointer is implementing the interface
-		if err := checkPointer(ptr); err != nil {
+		if err := checkPointer(ptr, warnings); err != nil {
 			return err
 		}
 		// in case the method Verify() is setting some parameter in the struct, we have to save these changes
 		v.Set(ptr.Elem())
 	} else {
-		if err := checkPointer(v); err != nil {
+		if err := checkPointer(v, warnings); err != nil {
 			return err
 		}
 		// for what is coming next, if it's a pointer, we need to access to the value itself
 		v = v.Elem()
 	}
 	switch v.Kind() {
+	case reflect.Interface:
+		// the implementations registered with RegisterKind are verified when they are pointers, as they cannot be modified otherwise.
+		if !v.IsNil() && v.Elem().Kind() == reflect.Ptr {
+			return verifyRec(v.Elem(), warnings)
+		}
 	case reflect.Slice:
 		for i := 0; i < v.Len(); i++ {
-			if err := verifyRec(v.Index(i)); err != nil {
+			if err := verifyRec(v.Index(i), warnings); err != nil {
 				return err
 			}
 		}
@@ -133,7 +171,7 @@ func verifyRec(conf reflect.Value) error {
 				// the field is not exported, so no need to look at it as we won't be able to set it in a later stage
 				continue
 			}
-			if err := verifyRec(attr); err != nil {
+			if err := verifyRec(attr, warnings); err != nil {
 				return err
 			}
 		}
@@ -142,21 +180,53 @@ func verifyRec(conf reflect.Value) error {
 	return nil
 }
 
+// Reloader is implemented by the Resolver to force the config to be read again, on demand.
+// It is typically called when the process receives the signal SIGHUP or through an HTTP endpoint.
+type Reloader interface {
+	Reload() error
+}
+
 type Resolver[T any] interface {
 	SetEnvPrefix(prefix string) Resolver[T]
+	SetEnvOptions(opts EnvOptions) Resolver[T]
 	SetConfigFile(filename string) Resolver[T]
 	SetConfigData(data []byte) Resolver[T]
 	AddChangeCallback(func(*T)) Resolver[T]
+	AddOverride(overrides ...string) Resolver[T]
+	SetDecrypter(decrypter Decrypter) Resolver[T]
+	SetPrometheusRegisterer(r prometheus.Registerer) Resolver[T]
+	AddMigration(fromVersion int, migration MigrationFunc) Resolver[T]
+	StrictEnv(isStrict bool) Resolver[T]
+	SetProfile(profile string) Resolver[T]
+	Strict(isStrict bool) Resolver[T]
 	Resolve(config *T) Validator
+	Raw() *RawConfig
+	Reloader
 }
 
 type configResolver[T any] struct {
 	Resolver[T]
 	prefix         string
+	envOptions     EnvOptions
 	strict         bool
+	strictEnv      bool
 	configFile     string
 	data           []byte
 	watchCallbacks []func(*T)
+	overrides      []string
+	decrypter      Decrypter
+	metrics        *metrics
+	migrations     []MigrationFunc
+	profile        string
+	// mutex serializes the reloads coming from the watcher and the ones forced with Reload.
+	mutex        sync.Mutex
+	resolved     bool
+	previousHash [sha1.Size]byte
+	raw          atomic.Pointer[RawConfig]
+	// includeWatchers contains a watcher for each file included in the config (see IncludeKey). It is protected by mutex.
+	includeWatchers map[string]*file.Watcher
+	// err is an error in the way the resolver is built (e.g. a migration added out of order). It is returned by Resolve.
+	err error
 }
 
 func NewResolver[T any]() Resolver[T] {
@@ -165,16 +235,32 @@ func NewResolver[T any]() Resolver[T] {
 	}
 }
 
+// Strict is setting a flag that will tell if the Resolver must fail when the config file contains a field that doesn't exist in the config.
+// It is enabled by default. It must be disabled when some sections of the file are only read with Raw.
 func (c *configResolver[T]) Strict(isStrict bool) Resolver[T] {
 	c.strict = isStrict
 	return c
 }
 
+// StrictEnv is setting a flag that will tell if the Resolver must fail when an environment variable starting with the prefix
+// doesn't match any field of the config. It helps to catch a typo in the name of a variable that otherwise would be silently ignored.
+func (c *configResolver[T]) StrictEnv(isStrict bool) Resolver[T] {
+	c.strictEnv = isStrict
+	return c
+}
+
 func (c *configResolver[T]) SetEnvPrefix(prefix string) Resolver[T] {
 	c.prefix = prefix
 	return c
 }
 
+// SetEnvOptions is the way to change how the fields of the config are mapped to the names of the environment variables,
+// like the delimiter or the case (see EnvOptions).
+func (c *configResolver[T]) SetEnvOptions(opts EnvOptions) Resolver[T] {
+	c.envOptions = opts
+	return c
+}
+
 // SetConfigFile is the way to set the path to the configFile (including the name of the file)
 func (c *configResolver[T]) SetConfigFile(filename string) Resolver[T] {
 	c.configFile = filename
@@ -193,21 +279,159 @@ func (c *configResolver[T]) AddChangeCallback(callback func(*T)) Resolver[T] {
 	return c
 }
 
+// AddOverride is the way to override some values of the config with a list of key/value like "server.port=9090".
+// The key is the path to the field using the yaml name of each attribute. The value is parsed as yaml.
+// The overrides are applied after the file and the environment, so they always win.
+func (c *configResolver[T]) AddOverride(overrides ...string) Resolver[T] {
+	c.overrides = append(c.overrides, overrides...)
+	return c
+}
+
+// SetDecrypter is the way to set the Decrypter used to decrypt the config files.
+// If not set, the files encrypted with age are decrypted using the identities provided by the environment (see NewAgeDecrypterFromEnv).
+func (c *configResolver[T]) SetDecrypter(decrypter Decrypter) Resolver[T] {
+	c.decrypter = decrypter
+	return c
+}
+
+// SetPrometheusRegisterer is the way to expose the metrics about the config (like the number of warnings or the result of the last reload) using the given registerer.
+// The metrics are not prefixed, use prometheus.WrapRegistererWithPrefix if you want to add a namespace.
+func (c *configResolver[T]) SetPrometheusRegisterer(r prometheus.Registerer) Resolver[T] {
+	c.metrics = newMetrics(r)
+	return c
+}
+
+// AddMigration registers a function that migrates the config from the version fromVersion to the version fromVersion+1.
+// The version of the config is read from the key VersionKey. The migrations are applied in order before decoding the config,
+// so a config written with an old schema is still accepted. A config with a version newer than the last migration is rejected.
+// The migrations must be added in order, starting from the version 1, otherwise Resolve returns an error.
+// The key VersionKey is only kept when the config has a field for it (e.g. `yaml:"config_version"`), to then contain the latest version.
+func (c *configResolver[T]) AddMigration(fromVersion int, migration MigrationFunc) Resolver[T] {
+	if fromVersion != len(c.migrations)+1 {
+		c.err = errors.Join(c.err, fmt.Errorf("the migration from the version %d is added out of order, the next migration must start from the version %d", fromVersion, len(c.migrations)+1))
+		return c
+	}
+	c.migrations = append(c.migrations, migration)
+	return c
+}
+
+// SetProfile activates the section of the profile (e.g. "prod") defined under the key ProfilesKey of the config file.
+// It is merged onto the rest of the config, so a single file can describe every environment.
+// Resolving the config fails if the profile is not defined.
+// Without profile, the key ProfilesKey is only removed when the config doesn't have a field for it.
+func (c *configResolver[T]) SetProfile(profile string) Resolver[T] {
+	c.profile = profile
+	return c
+}
+
 func (c *configResolver[T]) Resolve(config *T) Validator {
-	err := c.read(config)
+	raw, err := c.load(config)
+	c.metrics.observeReload(err)
 	if err == nil {
-		err = lamenv.Unmarshal(config, []string{c.prefix})
-		if len(c.watchCallbacks) != 0 && len(c.configFile) != 0 {
-			c.watchFile(config)
-		}
+		c.raw.Store(raw)
+		c.mutex.Lock()
+		c.resolved = true
+		c.previousHash, _ = c.hashConfig(config)
+		c.mutex.Unlock()
+	}
+	if err == nil && c.isWatching() {
+		c.watchFile()
+		c.mutex.Lock()
+		c.watchIncludes(raw.includes)
+		c.mutex.Unlock()
+	}
+	return c.newValidator(config, err)
+}
+
+// Reload reads again the config from the file (or the data), the environment and the overrides, then verifies it.
+// If the config is valid and different from the previous one, the callbacks added with AddChangeCallback are called.
+// It can only be used once the config has been resolved.
+// The callbacks are called once the reload is done, so they can trigger a new reload without blocking.
+func (c *configResolver[T]) Reload() error {
+	newConfig, callbacks, err := c.reload()
+	if err != nil {
+		return err
+	}
+	for _, callback := range callbacks {
+		callback(newConfig)
+	}
+	return nil
+}
+
+// reload loads the new config. It returns the callbacks to call with it, or nil when the config didn't change.
+func (c *configResolver[T]) reload() (*T, []func(*T), error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.resolved {
+		return nil, nil, errors.New("the config must be resolved before being reloaded")
+	}
+	var newConfig T
+	raw, err := c.load(&newConfig)
+	// like in Resolve, the hash is computed before the verification, as the method Verify can set the default values.
+	var newHash [sha1.Size]byte
+	if err == nil {
+		newHash, _ = c.hashConfig(&newConfig)
+		err = c.newValidator(&newConfig, nil).Verify()
+	}
+	c.metrics.observeReload(err)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the raw config is always updated, as the sections not modeled by the config can change without affecting its hash.
+	c.raw.Store(raw)
+	if c.isWatching() {
+		c.watchIncludes(raw.includes)
+	}
+
+	logrus.Debugln("New configuration loaded")
+
+	if c.previousHash == newHash {
+		return nil, nil, nil
 	}
-	return &validatorImpl{
+	c.previousHash = newHash
+	return &newConfig, slices.Clone(c.watchCallbacks), nil
+}
+
+// Raw returns the raw document of the config as resolved by the last successful call to Resolve or Reload.
+// It contains the sections that are not modeled by the config (e.g. the config of the plugins), so they can be decoded later without reading the file again.
+// It returns nil if the config has not been resolved yet.
+func (c *configResolver[T]) Raw() *RawConfig {
+	return c.raw.Load()
+}
+
+func (c *configResolver[T]) newValidator(config *T, err error) *validatorImpl {
+	v := &validatorImpl{
 		err:    err,
 		config: config,
 	}
+	if c.metrics != nil {
+		v.warningsGauge = c.metrics.warnings
+	}
+	return v
+}
+
+// load decodes in the config the file (or the data), then the environment and finally the overrides.
+func (c *configResolver[T]) load(config *T) (*RawConfig, error) {
+	raw, err := c.read(config)
+	if err == nil {
+		err = decodeEnv(config, c.prefix, c.envOptions)
+	}
+	if err == nil && c.strictEnv {
+		if unknown := unknownEnvVariables(reflect.TypeOf(config).Elem(), c.prefix, c.envOptions); len(unknown) > 0 {
+			err = unknownEnvError(unknown)
+		}
+	}
+	if err == nil {
+		err = applyOverrides(config, c.overrides, c.strict)
+	}
+	return raw, err
 }
 
-func (c *configResolver[T]) read(config *T) error {
+// read decodes the file (or the data) in the config. It also returns the raw document once the includes, the profile and the migrations are applied.
+func (c *configResolver[T]) read(config *T) (*RawConfig, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
 	var data []byte
 	var err error
 	if len(c.configFile) > 0 {
@@ -216,44 +440,84 @@ func (c *configResolver[T]) read(config *T) error {
 		data = c.data
 	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if len(data) == 0 {
 		// config can be entirely set from environment
-		return nil
+		return newRawConfig(nil)
+	}
+	data, err = decrypt(c.decrypter, data)
+	if err != nil {
+		return nil, err
+	}
+	data, includes, err := resolveIncludes(data, c.configFile, c.decrypter)
+	if err != nil {
+		return nil, err
+	}
+	data, err = applyProfile(data, c.profile, modelsKey(reflect.TypeOf(config), ProfilesKey))
+	if err != nil {
+		return nil, err
 	}
-	d := yaml.NewDecoder(bytes.NewReader(data))
-	d.KnownFields(c.strict)
-	return d.Decode(config)
+	data, err = migrate(data, c.migrations, modelsKey(reflect.TypeOf(config), VersionKey))
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeDocument(data, config, c.strict); err != nil {
+		return nil, err
+	}
+	raw, err := newRawConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	raw.includes = includes
+	return raw, nil
+}
+
+func (c *configResolver[T]) isWatching() bool {
+	return len(c.watchCallbacks) != 0 && len(c.configFile) != 0
 }
 
-func (c *configResolver[T]) watchFile(config *T) {
-	previousHash, _ := c.hashConfig(config)
+func (c *configResolver[T]) watchFile() {
+	if _, err := c.newWatcher(c.configFile); err != nil {
+		logrus.WithError(err).Errorf("Failed to watch the config file %s", c.configFile)
+	}
+}
 
-	err := file.Watch(c.configFile, func() {
-		var newConfig T
-		err := c.read(&newConfig)
+// watchIncludes watches the files included in the config, so a change of one of them reloads the config like a change of the config file.
+// The watchers of the files that are not included anymore are closed. The mutex must be held.
+func (c *configResolver[T]) watchIncludes(includes []string) {
+	watched := make(map[string]*file.Watcher, len(includes))
+	for _, include := range includes {
+		if w, ok := c.includeWatchers[include]; ok {
+			watched[include] = w
+			continue
+		}
+		w, err := c.newWatcher(include)
 		if err != nil {
-			logrus.WithError(err).Errorf("Cannot parse the watched config file %s", c.configFile)
-			return
+			logrus.WithError(err).Errorf("Failed to watch the included config file %s", include)
+			continue
 		}
+		watched[include] = w
+	}
+	for include, w := range c.includeWatchers {
+		if _, ok := watched[include]; !ok {
+			// closed asynchronously, as the reload can come from the callback of the watcher itself.
+			go func() { _ = w.Close() }()
+		}
+	}
+	c.includeWatchers = watched
+}
 
-		logrus.Debugln("New configuration loaded")
-
-		newHash, _ := c.hashConfig(&newConfig)
-		if previousHash == newHash {
+func (c *configResolver[T]) newWatcher(filename string) (*file.Watcher, error) {
+	return file.NewWatcherWithOptions(filename, file.Options{Debounce: watchDebounce, Checksum: true}, func(event file.Event) {
+		if !event.Op.Has(file.Create) && !event.Op.Has(file.Write) {
+			// the file has been removed, let's keep the current config until a new file is created
 			return
 		}
-		previousHash = newHash
-
-		for _, callback := range c.watchCallbacks {
-			callback(&newConfig)
+		if reloadErr := c.Reload(); reloadErr != nil {
+			logrus.WithError(reloadErr).Errorf("Cannot parse the watched config file %s", filename)
 		}
 	})
-
-	if err != nil {
-		logrus.WithError(err).Errorf("Failed to watch the config file %s", c.configFile)
-	}
 }
 
 func (c *configResolver[T]) readFromFile() ([]byte, error) {
@@ -270,6 +534,7 @@ func (c *configResolver[T]) readFromFile() ([]byte, error) {
 
 func (c *configResolver[T]) hashConfig(config *T) ([sha1.Size]byte, error) {
 	// We don't use the file content to calculate the hash.
+	// The watcher is already ignoring the writes that don't modify the content of the file (see file.Options.Checksum).
 	//
 	// The main reason is if the change doesn't affect a field
 	// tracked by the config, we don't want to notify the change.
@@ -1,11 +1,17 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
 )
 
 type foo struct {
@@ -142,3 +148,371 @@ func TestResolveImpl_WatchSliceConfigShouldApplyChanges(t *testing.T) {
 	assert.Equal(t, 4, updatedConfig[1])
 	assert.Equal(t, 5, updatedConfig[2])
 }
+
+func TestResolveImpl_Override(t *testing.T) {
+	type Server struct {
+		Port    int    `yaml:"port"`
+		Address string `yaml:"address"`
+	}
+	type Config struct {
+		Server Server   `yaml:"server"`
+		Tags   []string `yaml:"tags"`
+	}
+	var c Config
+	err := NewResolver[Config]().
+		SetConfigData([]byte("server:\n  port: 8080\n  address: localhost\n")).
+		AddOverride("server.port=9090", "tags=[a, b]").
+		Resolve(&c).
+		Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, Config{Server: Server{Port: 9090, Address: "localhost"}, Tags: []string{"a", "b"}}, c)
+
+	err = NewResolver[Config]().
+		AddOverride("server.prot=9090").
+		Resolve(&c).
+		Verify()
+	assert.Error(t, err)
+
+	err = NewResolver[Config]().
+		AddOverride("server.port").
+		Resolve(&c).
+		Verify()
+	assert.Error(t, err)
+}
+
+type warnedConfig struct {
+	Timeout int `yaml:"timeout"`
+}
+
+func (w *warnedConfig) Verify() error {
+	if w.Timeout < 10 {
+		return Warningf("timeout %d is too low", w.Timeout)
+	}
+	return nil
+}
+
+func TestValidatorImpl_VerifyShouldCollectWarnings(t *testing.T) {
+	type Config struct {
+		First  warnedConfig  `yaml:"first"`
+		Second *warnedConfig `yaml:"second"`
+	}
+	var c Config
+	v := NewResolver[Config]().
+		SetConfigData([]byte("first:\n  timeout: 1\nsecond:\n  timeout: 2\n")).
+		Resolve(&c)
+	assert.NoError(t, v.Verify())
+	assert.Len(t, Warnings(v), 2)
+	assert.True(t, IsWarning(Warnings(v)[0]))
+	assert.False(t, IsWarning(fmt.Errorf("error")))
+	assert.False(t, IsWarning(errors.Join(Warningf("warning"), fmt.Errorf("error"))))
+}
+
+func TestResolveImpl_ReloadMetrics(t *testing.T) {
+	type Config struct {
+		Field1 string `yaml:"field1"`
+	}
+
+	const configFile = "ut_resolve_reload_metrics.yaml"
+	if err := os.WriteFile(configFile, []byte("field1: toto"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(configFile)
+
+	registry := prometheus.NewRegistry()
+	var config Config
+	err := NewResolver[Config]().
+		SetConfigFile(configFile).
+		SetPrometheusRegisterer(registry).
+		AddChangeCallback(func(*Config) {}).
+		Resolve(&config).
+		Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newMetrics(registry)
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.lastReloadSuccessful))
+	assert.NotZero(t, testutil.ToFloat64(m.lastReloadTimestamp))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.reloads.WithLabelValues(reloadSuccess)))
+
+	// an invalid file must be reported as a failed reload
+	if err := os.WriteFile(configFile, []byte("field1: [toto"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(m.reloads.WithLabelValues(reloadFailure)) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.lastReloadSuccessful))
+}
+
+type requiredConfig struct {
+	Name string `yaml:"name"`
+}
+
+func (r *requiredConfig) Verify() error {
+	if len(r.Name) == 0 {
+		return fmt.Errorf("name cannot be empty")
+	}
+	return nil
+}
+
+func TestResolveImpl_Reload(t *testing.T) {
+	const configFile = "ut_resolve_reload.yaml"
+	if err := os.WriteFile(configFile, []byte("name: toto"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(configFile)
+
+	var config requiredConfig
+	var updatedConfig requiredConfig
+	callbackCallCount := 0
+	resolver := NewResolver[requiredConfig]().
+		SetConfigFile(configFile).
+		AddChangeCallback(func(newConfig *requiredConfig) {
+			callbackCallCount++
+			updatedConfig = *newConfig
+		})
+	assert.Error(t, resolver.Reload())
+	if err := resolver.Resolve(&config).Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	// no change, callbacks shouldn't be called
+	assert.NoError(t, resolver.Reload())
+	assert.Equal(t, 0, callbackCallCount)
+
+	// the new config is invalid, it must be rejected
+	if err := os.WriteFile(configFile, []byte("name: ''"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, resolver.Reload())
+
+	if err := os.WriteFile(configFile, []byte("name: yoyo"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, resolver.Reload())
+	// the watcher may have already dispatched the change
+	assert.Eventually(t, func() bool {
+		return callbackCallCount == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "toto", config.Name)
+	assert.Equal(t, "yoyo", updatedConfig.Name)
+}
+
+func TestResolveImpl_ReloadWithDefaultValues(t *testing.T) {
+	const configFile = "ut_resolve_reload_default.yaml"
+	if err := os.WriteFile(configFile, []byte("foo: {}"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(configFile)
+
+	var config myConfig
+	var callbackCallCount atomic.Int32
+	resolver := NewResolver[myConfig]().
+		SetConfigFile(configFile).
+		AddChangeCallback(func(*myConfig) {
+			callbackCallCount.Add(1)
+		})
+	if err := resolver.Resolve(&config).Verify(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "set", config.Foo.FieldToSet)
+
+	// the default value set by Verify is not a change of the config
+	assert.NoError(t, resolver.Reload())
+	if err := os.WriteFile(configFile, []byte("foo: {}"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(0), callbackCallCount.Load())
+}
+
+func TestResolveImpl_Migration(t *testing.T) {
+	type Database struct {
+		Address string `yaml:"address"`
+	}
+	type Config struct {
+		Version  int      `yaml:"config_version"`
+		Database Database `yaml:"database"`
+		Timeout  string   `yaml:"timeout"`
+	}
+	resolver := NewResolver[Config]().
+		AddMigration(1, func(doc *yaml.Node) error {
+			return MoveField(doc, "database.host", "database.address")
+		}).
+		AddMigration(2, func(doc *yaml.Node) error {
+			return MoveField(doc, "database.timeout", "timeout")
+		})
+
+	var c Config
+	err := resolver.SetConfigData([]byte("database:\n  host: localhost\n  timeout: 10s\n")).Resolve(&c).Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, Config{Version: 3, Database: Database{Address: "localhost"}, Timeout: "10s"}, c)
+
+	c = Config{}
+	err = resolver.SetConfigData([]byte("config_version: 3\ndatabase:\n  address: localhost\n")).Resolve(&c).Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, Config{Version: 3, Database: Database{Address: "localhost"}}, c)
+}
+
+func TestResolveImpl_MigrationWithoutVersionField(t *testing.T) {
+	type Config struct {
+		Address string `yaml:"address"`
+	}
+	resolver := NewResolver[Config]().
+		AddMigration(1, func(doc *yaml.Node) error {
+			return MoveField(doc, "host", "address")
+		})
+
+	var c Config
+	err := resolver.SetConfigData([]byte("host: localhost\n")).Resolve(&c).Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, Config{Address: "localhost"}, c)
+
+	c = Config{}
+	err = resolver.SetConfigData([]byte("config_version: 2\naddress: localhost\n")).Resolve(&c).Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, Config{Address: "localhost"}, c)
+}
+
+func TestResolveImpl_MigrationNewerVersion(t *testing.T) {
+	type Config struct {
+		Version int    `yaml:"config_version"`
+		Address string `yaml:"address"`
+	}
+	var c Config
+	err := NewResolver[Config]().
+		AddMigration(1, func(doc *yaml.Node) error {
+			return MoveField(doc, "host", "address")
+		}).
+		SetConfigData([]byte("config_version: 3\naddress: localhost\n")).
+		Resolve(&c).Verify()
+	assert.EqualError(t, err, "the config version 3 is not supported, the versions go from 1 to 2")
+
+	err = NewResolver[Config]().
+		AddMigration(1, func(doc *yaml.Node) error {
+			return MoveField(doc, "host", "address")
+		}).
+		SetConfigData([]byte("config_version: 0\nhost: localhost\n")).
+		Resolve(&c).Verify()
+	assert.EqualError(t, err, "the config version 0 is not supported, the versions go from 1 to 2")
+}
+
+func TestResolveImpl_MigrationOutOfOrder(t *testing.T) {
+	type Config struct {
+		Address string `yaml:"address"`
+	}
+	noop := func(*yaml.Node) error { return nil }
+	var c Config
+	err := NewResolver[Config]().
+		AddMigration(1, noop).
+		AddMigration(3, noop).
+		SetConfigData([]byte("address: localhost\n")).
+		Resolve(&c).Verify()
+	assert.EqualError(t, err, "the migration from the version 3 is added out of order, the next migration must start from the version 2")
+}
+
+func TestResolveImpl_StrictEnv(t *testing.T) {
+	type Etcd struct {
+		Host           string `yaml:"host"`
+		RequestTimeout int    `yaml:"request_timeout"`
+	}
+	type Config struct {
+		Etcd      Etcd              `yaml:"etcd"`
+		Endpoints []Etcd            `yaml:"endpoints"`
+		Labels    map[string]string `yaml:"labels"`
+	}
+	t.Setenv("UT_STRICT_ETCD_HOST", "localhost")
+	t.Setenv("UT_STRICT_ETCD_REQUEST_TIMEOUT", "10")
+	t.Setenv("UT_STRICT_ENDPOINTS_0_HOST", "localhost")
+	t.Setenv("UT_STRICT_LABELS_ENV", "prod")
+	var c Config
+	err := NewResolver[Config]().SetEnvPrefix("UT_STRICT").StrictEnv(true).Resolve(&c).Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost", c.Etcd.Host)
+
+	t.Setenv("UT_STRICT_ETDC_HOST", "localhost")
+	t.Setenv("UT_STRICT_ENDPOINTS_HOST", "localhost")
+	err = NewResolver[Config]().SetEnvPrefix("UT_STRICT").StrictEnv(true).Resolve(&c).Verify()
+	assert.EqualError(t, err, "unknown environment variables: UT_STRICT_ENDPOINTS_HOST, UT_STRICT_ETDC_HOST")
+}
+
+func TestResolveImpl_EnvOptions(t *testing.T) {
+	type Server struct {
+		Port          int           `yaml:"port"`
+		ListenAddress string        `yaml:"listen_address" env:"addr"`
+		Timeout       time.Duration `yaml:"timeout"`
+	}
+	type Config struct {
+		Server    Server            `yaml:"server"`
+		Endpoints []Server          `yaml:"endpoints"`
+		Labels    map[string]string `yaml:"labels"`
+		TLS       *Server           `yaml:"tls,omitempty"`
+	}
+	t.Setenv("UT_OPTS__SERVER__PORT", "9090")
+	t.Setenv("UT_OPTS__SERVER__ADDR", "localhost")
+	t.Setenv("UT_OPTS__SERVER__TIMEOUT", "10s")
+	t.Setenv("UT_OPTS__ENDPOINTS__0__LISTEN_ADDRESS", "no effect, the field is renamed")
+	t.Setenv("UT_OPTS__ENDPOINTS__1__PORT", "8080")
+	t.Setenv("UT_OPTS__LABELS__MY_ENV", "prod")
+	var c Config
+	err := NewResolver[Config]().
+		SetEnvPrefix("ut_opts").
+		SetEnvOptions(EnvOptions{Delimiter: "__"}).
+		Resolve(&c).
+		Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, Server{Port: 9090, ListenAddress: "localhost", Timeout: 10 * time.Second}, c.Server)
+	assert.Equal(t, []Server{{}, {Port: 8080}}, c.Endpoints)
+	assert.Equal(t, map[string]string{"my_env": "prod"}, c.Labels)
+	assert.Nil(t, c.TLS)
+
+	err = NewResolver[Config]().
+		SetEnvPrefix("ut_opts").
+		SetEnvOptions(EnvOptions{Delimiter: "__"}).
+		StrictEnv(true).
+		Resolve(&c).
+		Verify()
+	assert.EqualError(t, err, "unknown environment variables: UT_OPTS__ENDPOINTS__0__LISTEN_ADDRESS")
+
+	t.Setenv("ut_opts.server.port", "7070")
+	var lower Config
+	err = NewResolver[Config]().
+		SetEnvPrefix("UT_OPTS").
+		SetEnvOptions(EnvOptions{Delimiter: ".", Casing: EnvLowerCase}).
+		Resolve(&lower).
+		Verify()
+	assert.NoError(t, err)
+	assert.Equal(t, 7070, lower.Server.Port)
+}
+
+func TestResolveImpl_ReloadFromCallback(t *testing.T) {
+	type Config struct {
+		Port int `yaml:"port"`
+	}
+	t.Setenv("UT_REENTRANT_PORT", "8080")
+	var callbackCallCount atomic.Int32
+	resolver := NewResolver[Config]().SetEnvPrefix("UT_REENTRANT")
+	resolver.AddChangeCallback(func(*Config) {
+		// a callback can reload the config without blocking
+		if callbackCallCount.Add(1) == 1 {
+			assert.NoError(t, resolver.Reload())
+		}
+	})
+	var c Config
+	if err := resolver.Resolve(&c).Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("UT_REENTRANT_PORT", "9090")
+	done := make(chan error)
+	go func() {
+		done <- resolver.Reload()
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("the reload is blocked")
+	}
+	assert.Equal(t, int32(1), callbackCallCount.Load())
+}
@@ -0,0 +1,79 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Warning is an error that can be returned by the method Verify to highlight a non-fatal issue in the config.
+// Unlike the other errors, a Warning doesn't stop the verification of the config.
+// Multiple warnings can be returned at once using errors.Join.
+//
+// Example:
+//
+//	func (c *Config) Verify() error {
+//	  if c.RequestTimeout < time.Second {
+//	    return config.Warningf("request_timeout (%s) is probably too low", c.RequestTimeout)
+//	  }
+//	  return nil
+//	}
+type Warning struct {
+	msg string
+}
+
+func (w *Warning) Error() string {
+	return w.msg
+}
+
+// Warningf creates a new Warning according to the format specifier.
+func Warningf(format string, args ...interface{}) error {
+	return &Warning{msg: fmt.Sprintf(format, args...)}
+}
+
+// IsWarning returns true if err is a Warning or if it only contains warnings (when built with errors.Join).
+func IsWarning(err error) bool {
+	if err == nil {
+		return false
+	}
+	_, hardErrors := splitWarnings(err)
+	return len(hardErrors) == 0
+}
+
+// Warnings returns the warnings collected during the last call to the method Verify of the Validator returned by the Resolver.
+func Warnings(v Validator) []error {
+	if impl, ok := v.(*validatorImpl); ok {
+		return impl.warnings
+	}
+	return nil
+}
+
+// splitWarnings separates the warnings from the other errors.
+func splitWarnings(err error) ([]error, []error) {
+	var warning *Warning
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var warnings, hardErrors []error
+		for _, e := range joined.Unwrap() {
+			w, h := splitWarnings(e)
+			warnings = append(warnings, w...)
+			hardErrors = append(hardErrors, h...)
+		}
+		return warnings, hardErrors
+	}
+	if errors.As(err, &warning) {
+		return []error{err}, nil
+	}
+	return nil, []error{err}
+}
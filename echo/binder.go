@@ -0,0 +1,212 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	MIMEApplicationYAML = "application/yaml"
+
+	binderContextKey = "perses.binder"
+)
+
+var yamlMIMETypes = []string{MIMEApplicationYAML, "application/x-yaml", "text/yaml"}
+
+type StrictBinderConfig struct {
+	// MaxBodySize is the maximum size in bytes of the body. The requests exceeding it get the status code 413. By default, it is 1MiB.
+	MaxBodySize int64
+	// MaxDepth is the maximum number of nested objects and arrays in the body. By default, it is 32.
+	MaxDepth int
+	// AllowYAML accepts the bodies encoded in YAML (see the header Content-Type) in addition to JSON.
+	AllowYAML bool
+}
+
+// StrictBinder is an echo.Binder rejecting the JSON (and YAML) bodies containing fields that are unknown by the target struct,
+// so the clients notice immediately a typo instead of having a field silently ignored.
+// The other kinds of bodies (like forms) are bound as done by echo.DefaultBinder.
+type StrictBinder struct {
+	echo.DefaultBinder
+	config StrictBinderConfig
+}
+
+func NewStrictBinder(config StrictBinderConfig) *StrictBinder {
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 1 << 20
+	}
+	if config.MaxDepth <= 0 {
+		config.MaxDepth = 32
+	}
+	return &StrictBinder{config: config}
+}
+
+// Bind follows the same steps as echo.DefaultBinder: the path parameters, the query parameters (only for the GET, DELETE and HEAD requests) and then the body.
+func (b *StrictBinder) Bind(i interface{}, c echo.Context) error {
+	if err := b.BindPathParams(c, i); err != nil {
+		return err
+	}
+	method := c.Request().Method
+	if method == http.MethodGet || method == http.MethodDelete || method == http.MethodHead {
+		if err := b.BindQueryParams(c, i); err != nil {
+			return err
+		}
+	}
+	return b.BindBody(c, i)
+}
+
+func (b *StrictBinder) BindBody(c echo.Context, i interface{}) error {
+	req := c.Request()
+	if req.ContentLength == 0 {
+		return nil
+	}
+	contentType := req.Header.Get(echo.HeaderContentType)
+	isJSON := strings.HasPrefix(contentType, echo.MIMEApplicationJSON)
+	isYAML := b.config.AllowYAML && isYAMLContentType(contentType)
+	if !isJSON && !isYAML {
+		return b.DefaultBinder.BindBody(c, i)
+	}
+	data, err := io.ReadAll(io.LimitReader(req.Body, b.config.MaxBodySize+1))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "unable to read the body").SetInternal(err)
+	}
+	if int64(len(data)) > b.config.MaxBodySize {
+		return echo.ErrStatusRequestEntityTooLarge
+	}
+	if isJSON {
+		err = b.decodeJSON(data, i)
+	} else {
+		err = b.decodeYAML(data, i)
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+	}
+	return nil
+}
+
+func (b *StrictBinder) decodeJSON(data []byte, i interface{}) error {
+	if err := checkJSONDepth(data, b.config.MaxDepth); err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(i); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("the body must contain a single JSON document")
+	}
+	return nil
+}
+
+func (b *StrictBinder) decodeYAML(data []byte, i interface{}) error {
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal(data, node); err != nil {
+		return err
+	}
+	if depthOfNode(node) > b.config.MaxDepth {
+		return fmt.Errorf("the body exceeds the maximum depth of %d", b.config.MaxDepth)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	return decoder.Decode(i)
+}
+
+// checkJSONDepth returns an error if the objects and arrays of the JSON document are nested deeper than maxDepth.
+func checkJSONDepth(data []byte, maxDepth int) error {
+	depth := 0
+	inString := false
+	escaped := false
+	for _, char := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case char == '\\':
+				escaped = true
+			case char == '"':
+				inString = false
+			}
+			continue
+		}
+		switch char {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				return fmt.Errorf("the body exceeds the maximum depth of %d", maxDepth)
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return nil
+}
+
+// depthOfNode returns the number of nested mappings and sequences of the YAML node.
+func depthOfNode(node *yaml.Node) int {
+	maxChild := 0
+	for _, child := range node.Content {
+		maxChild = max(maxChild, depthOfNode(child))
+	}
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		return maxChild + 1
+	}
+	return maxChild
+}
+
+func isYAMLContentType(contentType string) bool {
+	for _, mime := range yamlMIMETypes {
+		if strings.HasPrefix(contentType, mime) {
+			return true
+		}
+	}
+	return false
+}
+
+// UseBinder is a middleware selecting the binder used by echo.Context.Bind for the routes it is applied to.
+// It makes possible to use a different binder per group of routes, for example:
+//
+//	group := e.Group("/api/v2", persesEcho.UseBinder(persesEcho.NewStrictBinder(persesEcho.StrictBinderConfig{AllowYAML: true})))
+//
+// It only works with the servers created by the Builder (see Builder.Binder).
+func UseBinder(binder echo.Binder) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(binderContextKey, binder)
+			return next(c)
+		}
+	}
+}
+
+// routeBinder uses the binder selected by the middleware UseBinder, or the default one.
+type routeBinder struct {
+	fallback echo.Binder
+}
+
+func (r *routeBinder) Bind(i interface{}, c echo.Context) error {
+	if binder, ok := c.Get(binderContextKey).(echo.Binder); ok {
+		return binder.Bind(i, c)
+	}
+	return r.fallback.Bind(i, c)
+}
@@ -0,0 +1,88 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+type bindTarget struct {
+	Name   string            `json:"name" yaml:"name" param:"name"`
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Spec   interface{}       `json:"spec,omitempty" yaml:"spec,omitempty"`
+}
+
+type binderAPI struct{}
+
+func (binderAPI) RegisterRoute(e *echo.Echo) {
+	handler := func(c echo.Context) error {
+		target := &bindTarget{}
+		if err := c.Bind(target); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, target)
+	}
+	e.POST("/default", handler)
+	strict := e.Group("/strict", UseBinder(NewStrictBinder(StrictBinderConfig{MaxBodySize: 100, MaxDepth: 3, AllowYAML: true})))
+	strict.POST("/:name", handler)
+}
+
+func TestStrictBinder(t *testing.T) {
+	handler, err := NewBuilder(":0").
+		PrometheusRegisterer(prometheus.NewRegistry()).
+		APIRegistration(binderAPI{}).
+		BuildHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSuites := []struct {
+		title        string
+		path         string
+		contentType  string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{title: "unknown field ignored by the default binder", path: "/default", contentType: echo.MIMEApplicationJSON, body: `{"name":"foo","unknown":1}`, expectedCode: http.StatusOK, expectedBody: `{"name":"foo"}`},
+		{title: "unknown field rejected", path: "/strict/bar", contentType: echo.MIMEApplicationJSON, body: `{"name":"foo","unknown":1}`, expectedCode: http.StatusBadRequest},
+		{title: "valid JSON", path: "/strict/bar", contentType: echo.MIMEApplicationJSON, body: `{"labels":{"a":"b"}}`, expectedCode: http.StatusOK, expectedBody: `{"name":"bar","labels":{"a":"b"}}`},
+		{title: "valid YAML", path: "/strict/bar", contentType: MIMEApplicationYAML, body: "name: foo\nlabels:\n  a: b\n", expectedCode: http.StatusOK, expectedBody: `{"name":"foo","labels":{"a":"b"}}`},
+		{title: "unknown field rejected in YAML", path: "/strict/bar", contentType: MIMEApplicationYAML, body: "unknown: 1\n", expectedCode: http.StatusBadRequest},
+		{title: "YAML not accepted by the default binder", path: "/default", contentType: MIMEApplicationYAML, body: "name: foo\n", expectedCode: http.StatusUnsupportedMediaType},
+		{title: "too deep JSON", path: "/strict/bar", contentType: echo.MIMEApplicationJSON, body: `{"spec":[[["{[[["]]]}`, expectedCode: http.StatusBadRequest},
+		{title: "brackets in strings are ignored", path: "/strict/bar", contentType: echo.MIMEApplicationJSON, body: `{"spec":["[[[\"{{"]}`, expectedCode: http.StatusOK, expectedBody: `{"name":"bar","spec":["[[[\"{{"]}`},
+		{title: "too deep YAML", path: "/strict/bar", contentType: MIMEApplicationYAML, body: "spec:\n  a:\n    b:\n      c: d\n", expectedCode: http.StatusBadRequest},
+		{title: "body too large", path: "/strict/bar", contentType: echo.MIMEApplicationJSON, body: `{"name":"` + strings.Repeat("a", 100) + `"}`, expectedCode: http.StatusRequestEntityTooLarge},
+		{title: "multiple documents", path: "/strict/bar", contentType: echo.MIMEApplicationJSON, body: `{"name":"foo"}{"name":"bar"}`, expectedCode: http.StatusBadRequest},
+	}
+	for _, test := range testSuites {
+		t.Run(test.title, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, test.contentType)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, test.expectedCode, rec.Code)
+			if len(test.expectedBody) > 0 {
+				assert.JSONEq(t, test.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/perses/common/health"
+)
+
+var (
+	// http path for the health exposition
+	healthPath string
+)
+
+func init() {
+	flag.StringVar(&healthPath, "web.health-path", "/health", "Path under which to expose the health of the application.")
+}
+
+// NewHealthAPI returns the API exposing the report of the health registry.
+// It replies with the status code 200 when every check is up and 503 otherwise.
+func NewHealthAPI(registry *health.Registry) Register {
+	return &healthAPI{registry: registry}
+}
+
+type healthAPI struct {
+	Register
+	registry *health.Registry
+}
+
+func (h *healthAPI) RegisterRoute(e *echo.Echo) {
+	e.GET(healthPath, h.check)
+}
+
+func (h *healthAPI) check(ctx echo.Context) error {
+	report := h.registry.Check(ctx.Request().Context())
+	code := http.StatusOK
+	if report.Status != health.StatusUp {
+		code = http.StatusServiceUnavailable
+	}
+	return ctx.JSON(code, report)
+}
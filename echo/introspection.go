@@ -0,0 +1,101 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const middlewareDebugPath = "/debug/middleware"
+
+// closureSuffix matches the suffix added by the compiler to the name of the closures and the method values.
+var closureSuffix = regexp.MustCompile(`(\.func\d+)+$|-fm$`)
+
+// uniqueMiddleware are the middleware that must not be used more than once in the same chain.
+// The key is the suffix of the name of the function creating the middleware.
+var uniqueMiddleware = map[string]string{
+	"middleware.GzipWithConfig":       "gzip",
+	"middleware.RecoverWithConfig":    "recover",
+	"middleware.DecompressWithConfig": "decompress",
+	"middleware.RequestLogger":        "request logger",
+	"middleware.TimeoutWithConfig":    "timeout",
+	"middleware.SecureWithConfig":     "security headers",
+	"middleware.BodyLimitWithConfig":  "body limit",
+}
+
+// MiddlewareChain describes the middleware executed by the server, in order.
+type MiddlewareChain struct {
+	// Pre are the middleware executed before the router.
+	Pre []string `json:"pre"`
+	// Route are the middleware executed after the router.
+	Route []string `json:"route"`
+}
+
+func newMiddlewareChain(pre []echo.MiddlewareFunc, route []echo.MiddlewareFunc) MiddlewareChain {
+	chain := MiddlewareChain{Pre: make([]string, 0, len(pre)), Route: make([]string, 0, len(route))}
+	for _, mdw := range pre {
+		chain.Pre = append(chain.Pre, middlewareName(mdw))
+	}
+	for _, mdw := range route {
+		chain.Route = append(chain.Route, middlewareName(mdw))
+	}
+	return chain
+}
+
+// middlewareName returns the name of the function that created the middleware.
+func middlewareName(mdw echo.MiddlewareFunc) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(mdw).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	return closureSuffix.ReplaceAllString(fn.Name(), "")
+}
+
+// validate returns an error if a middleware that must be unique is used several times in the same chain.
+func (m MiddlewareChain) validate() error {
+	for _, names := range [][]string{m.Pre, m.Route} {
+		seen := make(map[string]bool)
+		for _, name := range names {
+			for suffix, kind := range uniqueMiddleware {
+				if !strings.HasSuffix(name, suffix) {
+					continue
+				}
+				if seen[kind] {
+					return fmt.Errorf("the %s middleware is used more than once, check the middleware added to the default ones", kind)
+				}
+				seen[kind] = true
+			}
+		}
+	}
+	return nil
+}
+
+func (m MiddlewareChain) log() {
+	logrus.Debugf("middleware executed before the router: %s", strings.Join(m.Pre, ", "))
+	logrus.Debugf("middleware executed after the router: %s", strings.Join(m.Route, ", "))
+}
+
+func (m MiddlewareChain) registerRoute(e *echo.Echo) {
+	e.GET(middlewareDebugPath, func(c echo.Context) error {
+		return c.JSON(http.StatusOK, m)
+	})
+}
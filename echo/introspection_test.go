@@ -0,0 +1,62 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder_DebugMiddleware(t *testing.T) {
+	handler, err := NewBuilder(":0").
+		PrometheusRegisterer(prometheus.NewRegistry()).
+		APIRegistration(jsonAPI{}).
+		DebugMiddleware(true).
+		BuildHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, middlewareDebugPath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	chain := MiddlewareChain{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &chain); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{
+		"github.com/labstack/echo/v4/middleware.RemoveTrailingSlashWithConfig",
+		"github.com/perses/common/echo.(*longLivedTracker).middleware",
+	}, chain.Pre)
+	assert.Equal(t, []string{
+		"github.com/labstack/echo/v4/middleware.RecoverWithConfig",
+		"github.com/perses/common/echo/middleware.RequestLogger",
+		"github.com/perses/common/echo/middleware.LoggerWithConfig",
+		"github.com/labstack/echo/v4/middleware.GzipWithConfig",
+	}, chain.Route)
+}
+
+func TestBuilder_ConflictingMiddleware(t *testing.T) {
+	_, err := NewBuilder(":0").
+		PrometheusRegisterer(prometheus.NewRegistry()).
+		APIRegistration(jsonAPI{}).
+		Middleware(middleware.Gzip()).
+		BuildHandler()
+	assert.EqualError(t, err, "the gzip middleware is used more than once, check the middleware added to the default ones")
+}
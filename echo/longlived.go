@@ -0,0 +1,105 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+const longLivedKey = "perses.long-lived"
+
+// neverClosed is returned to the requests that are not served by a server built with the Builder.
+var neverClosed = make(chan struct{})
+
+// LongLived must be called by the handlers keeping the connection open for a long time (Server-Sent Events, long polling, ...).
+// The channel returned is closed when the server starts to shut down. The handler is then expected to notify the client
+// (by sending a final event for example), so it can reconnect cleanly to another instance, and to return.
+// done must be called when the handler returns.
+//
+// When the server shuts down, it waits for the long-lived requests to end (within the shutdown timeout) before closing the connections.
+//
+//	func (a *api) stream(ctx echo.Context) error {
+//	    shutdown, done := commonEcho.LongLived(ctx)
+//	    defer done()
+//	    for {
+//	        select {
+//	        case <-shutdown:
+//	            return sendEvent(ctx, "reconnect")
+//	        case event := <-a.events:
+//	            if err := sendEvent(ctx, event); err != nil {
+//	                return err
+//	            }
+//	        }
+//	    }
+//	}
+func LongLived(ctx echo.Context) (shutdown <-chan struct{}, done func()) {
+	tracker, ok := ctx.Get(longLivedKey).(*longLivedTracker)
+	if !ok {
+		return neverClosed, func() {}
+	}
+	return tracker.add()
+}
+
+// longLivedTracker keeps track of the long-lived requests to notify them when the server shuts down.
+type longLivedTracker struct {
+	mutex    sync.Mutex
+	wg       sync.WaitGroup
+	shutdown chan struct{}
+	closed   bool
+}
+
+func newLongLivedTracker() *longLivedTracker {
+	return &longLivedTracker{shutdown: make(chan struct{})}
+}
+
+func (t *longLivedTracker) middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		ctx.Set(longLivedKey, t)
+		return next(ctx)
+	}
+}
+
+func (t *longLivedTracker) add() (<-chan struct{}, func()) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.closed {
+		// the server is already shutting down, the request must end immediately.
+		return t.shutdown, func() {}
+	}
+	t.wg.Add(1)
+	var once sync.Once
+	return t.shutdown, func() { once.Do(t.wg.Done) }
+}
+
+// close notifies the long-lived requests and waits for them to end or for the context to be done.
+func (t *longLivedTracker) close(ctx context.Context) {
+	t.mutex.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.shutdown)
+	}
+	t.mutex.Unlock()
+	ended := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(ended)
+	}()
+	select {
+	case <-ended:
+	case <-ctx.Done():
+	}
+}
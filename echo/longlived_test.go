@@ -0,0 +1,54 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLongLived(t *testing.T) {
+	tracker := newLongLivedTracker()
+	e := echo.New()
+	started := make(chan struct{})
+	ended := make(chan struct{})
+	e.Pre(tracker.middleware)
+	e.GET("/stream", func(ctx echo.Context) error {
+		shutdown, done := LongLived(ctx)
+		defer done()
+		close(started)
+		<-shutdown
+		close(ended)
+		return ctx.String(http.StatusOK, "reconnect")
+	})
+	rec := httptest.NewRecorder()
+	go e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	tracker.close(ctx)
+	assert.NoError(t, ctx.Err())
+	select {
+	case <-ended:
+	default:
+		t.Fatal("the long-lived request has not been notified")
+	}
+}
@@ -14,13 +14,24 @@
 package echo
 
 import (
+	"crypto/subtle"
 	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
 )
 
+// scrapeTimeoutHeader is the header set by Prometheus with the timeout of the scrape in seconds.
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
 var (
 	// http path for telemetry exposition
 	telemetryPath string
@@ -30,37 +41,139 @@ func init() {
 	flag.StringVar(&telemetryPath, "web.telemetry-path", "/metrics", "Path under which to expose metrics.")
 }
 
+// MetricsOptions configures the API exposing the metrics.
+type MetricsOptions struct {
+	// Registerer is used to register the metrics about the endpoint itself.
+	// When nil, the Builder fills it with its own registerer (see Builder.PrometheusRegisterer).
+	Registerer prometheus.Registerer
+	// Gatherer provides the metrics exposed. When nil, the Registerer is used if it is a Gatherer (like prometheus.Registry),
+	// prometheus.DefaultGatherer otherwise.
+	Gatherer prometheus.Gatherer
+	// DisableCompression should be used if you are using the gzip middleware at a higher level (meaning that the endpoint /metrics is going to be compressed by the middleware).
+	// The following issues give a bit more context:
+	// * https://github.com/prometheus/prometheus/issues/5085
+	// * https://github.com/prometheus/client_golang/issues/622g
+	DisableCompression bool
+	// EnableOpenMetrics serves the OpenMetrics format when the scraper requests it.
+	EnableOpenMetrics bool
+	// MaxRequestsInFlight limits the number of concurrent scrapes. Additional scrapes get the status code 503. Zero means no limit.
+	MaxRequestsInFlight int
+	// Timeout is the maximum duration to gather the metrics. The scrape gets the status code 503 when it is exceeded. Zero means no timeout.
+	Timeout time.Duration
+	// HonorScrapeTimeout uses the timeout sent by Prometheus in the header X-Prometheus-Scrape-Timeout-Seconds when it is lower than Timeout.
+	HonorScrapeTimeout bool
+	// BasicAuth protects the endpoint with a basic authentication when it is set.
+	BasicAuth *MetricsBasicAuth
+	// ErrorHandling defines how the scrape reacts when a collector fails (promhttp.HTTPErrorOnError by default).
+	// With promhttp.ContinueOnError, the metrics of the collectors in error are missing from the exposition.
+	ErrorHandling promhttp.HandlerErrorHandling
+	// ErrorLog is used to log the errors occurring while gathering and encoding the metrics. When nil, they are logged with logrus at the level error.
+	ErrorLog promhttp.Logger
+}
+
+type MetricsBasicAuth struct {
+	Username string
+	Password string
+}
+
+// NewMetricsAPI returns the API exposing the metrics gathered by gatherer.
+// When r or gatherer is nil, the Builder fills them with its own registerer (see Builder.PrometheusRegisterer).
 func NewMetricsAPI(disableCompression bool, r prometheus.Registerer, gatherer prometheus.Gatherer) Register {
-	return &metrics{
-		disableCompression: disableCompression,
-		promRegisterer:     r,
-		promGatherer:       gatherer}
+	return NewMetricsAPIWithOptions(MetricsOptions{
+		Registerer:         r,
+		Gatherer:           gatherer,
+		DisableCompression: disableCompression,
+	})
+}
+
+// NewMetricsAPIWithRegistry returns the API exposing the metrics of the registry.
+func NewMetricsAPIWithRegistry(reg *prometheus.Registry, disableCompression bool) Register {
+	return NewMetricsAPI(disableCompression, reg, reg)
+}
+
+// NewMetricsAPIWithOptions returns the API exposing the metrics configured with the options.
+func NewMetricsAPIWithOptions(opts MetricsOptions) Register {
+	return &metrics{opts: opts}
 }
 
 // metrics is a struct than handles the endpoint /metrics
-// It should be used through the Builder like that: Builder.APIRegistration(NewMetricsAPI(true))
+// It should be used through the Builder like that: Builder.APIRegistration(NewMetricsAPI(true, nil, nil))
 type metrics struct {
 	Register
-	// disableCompression should be used if you are using the gzip middleware at a higher level (meaning that the endpoint /metrics is going to be compressed by the middleware).
-	// The following issues give a bit more context:
-	// * https://github.com/prometheus/prometheus/issues/5085
-	// * https://github.com/prometheus/client_golang/issues/622g
-	disableCompression bool
-	promRegisterer     prometheus.Registerer
-	promGatherer       prometheus.Gatherer
+	opts MetricsOptions
 }
 
 func (m *metrics) RegisterRoute(e *echo.Echo) {
-	if m.promRegisterer == nil {
-		m.promRegisterer = prometheus.DefaultRegisterer
+	m.useRegisterer(prometheus.DefaultRegisterer)
+	if m.opts.ErrorLog == nil {
+		m.opts.ErrorLog = logrusErrorLogger{}
 	}
-	e.GET(telemetryPath, echo.WrapHandler(
-		promhttp.InstrumentMetricHandler(
-			m.promRegisterer, promhttp.HandlerFor(
-				m.promGatherer, promhttp.HandlerOpts{
-					DisableCompression: m.disableCompression,
-				},
-			),
+	var handler http.Handler = promhttp.InstrumentMetricHandler(
+		m.opts.Registerer, promhttp.HandlerFor(
+			m.opts.Gatherer, promhttp.HandlerOpts{
+				DisableCompression:  m.opts.DisableCompression,
+				EnableOpenMetrics:   m.opts.EnableOpenMetrics,
+				MaxRequestsInFlight: m.opts.MaxRequestsInFlight,
+				Timeout:             m.opts.Timeout,
+				ErrorHandling:       m.opts.ErrorHandling,
+				ErrorLog:            m.opts.ErrorLog,
+			},
 		),
-	))
+	)
+	if m.opts.HonorScrapeTimeout {
+		handler = m.honorScrapeTimeout(handler)
+	}
+	var mdws []echo.MiddlewareFunc
+	if m.opts.BasicAuth != nil {
+		mdws = append(mdws, middleware.BasicAuth(m.checkBasicAuth))
+	}
+	e.GET(telemetryPath, echo.WrapHandler(handler), mdws...)
+}
+
+func (m *metrics) honorScrapeTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seconds, err := strconv.ParseFloat(r.Header.Get(scrapeTimeoutHeader), 64)
+		if err != nil || seconds <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		timeout := time.Duration(seconds * float64(time.Second))
+		if m.opts.Timeout > 0 && m.opts.Timeout < timeout {
+			// the timeout of promhttp is already shorter
+			next.ServeHTTP(w, r)
+			return
+		}
+		http.TimeoutHandler(next, timeout, "Exceeded the scrape timeout while gathering the metrics").ServeHTTP(w, r)
+	})
+}
+
+func (m *metrics) checkBasicAuth(username string, password string, _ echo.Context) (bool, error) {
+	usernameOK := subtle.ConstantTimeCompare([]byte(username), []byte(m.opts.BasicAuth.Username)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(m.opts.BasicAuth.Password)) == 1
+	return usernameOK && passwordOK, nil
+}
+
+// useRegisterer sets the registerer and the gatherer when they haven't been provided.
+func (m *metrics) useRegisterer(r prometheus.Registerer) {
+	if m.opts.Registerer == nil {
+		m.opts.Registerer = r
+	}
+	if m.opts.Gatherer == nil {
+		m.opts.Gatherer = gathererOf(m.opts.Registerer)
+	}
+}
+
+// gathererOf returns the registerer if it is also a Gatherer (like prometheus.Registry), prometheus.DefaultGatherer otherwise.
+func gathererOf(r prometheus.Registerer) prometheus.Gatherer {
+	if g, ok := r.(prometheus.Gatherer); ok {
+		return g
+	}
+	return prometheus.DefaultGatherer
+}
+
+// logrusErrorLogger logs the errors of promhttp with logrus.
+type logrusErrorLogger struct{}
+
+func (logrusErrorLogger) Println(v ...interface{}) {
+	logrus.WithField("path", telemetryPath).Error(strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
 }
@@ -0,0 +1,41 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"errors"
+
+	"github.com/labstack/echo/v4"
+)
+
+// StatusClientClosedRequest is the status code (not standard, introduced by nginx) used in the logs and the metrics
+// for the requests aborted by the client before the handler finished. Otherwise, they would appear with the status code
+// set by the handler (or 0), skewing the statistics.
+const StatusClientClosedRequest = 499
+
+// isAborted returns true when the client went away before the handler finished.
+// ctx must be the context of the request saved before calling the next handler, as the inner middlewares can replace it
+// with a context they cancel once they are done (like Timeout).
+func isAborted(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
+// responseStatus returns the status code to use in the logs and the metrics. ctx is the context passed to isAborted.
+func responseStatus(ctx context.Context, c echo.Context) int {
+	if isAborted(ctx) {
+		return StatusClientClosedRequest
+	}
+	return c.Response().Status
+}
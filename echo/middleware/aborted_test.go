@@ -0,0 +1,88 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAbortedRequest(t *testing.T) {
+	metrics, err := NewMetrics("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := test.NewGlobal()
+	defer hook.Reset()
+	e := echo.New()
+	e.Use(Logger(), metrics.ProcessHTTPRequest)
+	ctx, cancel := context.WithCancel(context.Background())
+	e.GET("/", func(c echo.Context) error {
+		// the client goes away while the request is processed
+		cancel()
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/ok", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.totalHTTPRequest.WithLabelValues("499", "/", http.MethodGet)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.totalHTTPRequest.WithLabelValues("200", "/ok", http.MethodGet)))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.durationHTTPRequest))
+
+	entries := hook.AllEntries()
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, StatusClientClosedRequest, entries[0].Data["status"])
+		assert.Equal(t, true, entries[0].Data["aborted"])
+		assert.Equal(t, http.StatusOK, entries[1].Data["status"])
+		assert.NotContains(t, entries[1].Data, "aborted")
+	}
+	assert.Equal(t, logrus.InfoLevel, entries[0].Level)
+}
+
+func TestAbortedRequest_Timeout(t *testing.T) {
+	metrics, err := NewMetrics("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook := test.NewGlobal()
+	defer hook.Reset()
+	e := echo.New()
+	// the context installed by the timeout is canceled once the handler is done, it must not be seen as an aborted request
+	e.Use(Logger(), metrics.ProcessHTTPRequest, TimeoutWithConfig(TimeoutConfig{Default: 10 * time.Second}))
+	e.GET("/ok", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ok", nil))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.totalHTTPRequest.WithLabelValues("200", "/ok", http.MethodGet)))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.totalHTTPRequest))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.durationHTTPRequest))
+	entries := hook.AllEntries()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, http.StatusOK, entries[0].Data["status"])
+		assert.NotContains(t, entries[0].Data, "aborted")
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// HeaderXCache tells whether the response comes from the cache (HIT), from the cache while it is refreshed (STALE) or from the handler (MISS).
+	HeaderXCache = "X-Cache"
+
+	labelResult = "result"
+
+	cacheHit   = "hit"
+	cacheStale = "stale"
+	cacheMiss  = "miss"
+)
+
+type ResponseCacheConfig struct {
+	Skipper middleware.Skipper
+	// TTL is how long a response is served from the cache. By default, it is 1 minute.
+	TTL time.Duration
+	// StaleWhileRevalidate is how long an expired response can still be served while it is refreshed in the background.
+	// Zero means the expired responses are never served.
+	StaleWhileRevalidate time.Duration
+	// MaxEntries is the number of responses kept. When it is reached, the least recently used response is evicted. By default, it is 1000.
+	MaxEntries int
+}
+
+type cacheEntry struct {
+	key          string
+	route        string
+	tenant       string
+	status       int
+	header       http.Header
+	body         []byte
+	storedAt     time.Time
+	revalidating bool
+	element      *list.Element
+}
+
+// ResponseCache is a middleware caching in memory the successful responses of the GET requests.
+// The responses are identified by the path of the request, its query parameters and its tenant (see Tenancy).
+// It must only be used on the routes returning the same response for the same key, whoever the user is.
+// The responses setting a cookie are not cached.
+// The responses are stored before being compressed by the middleware gzip, so an entry is served to every client whatever the encoding it accepts.
+// It provides the metrics to monitor the efficiency of the cache and must be registered in a prometheus.Registerer.
+type ResponseCache struct {
+	config   ResponseCacheConfig
+	mutex    sync.Mutex
+	entries  map[string]*cacheEntry
+	lru      *list.List
+	requests *prometheus.CounterVec
+	now      func() time.Time
+}
+
+func NewResponseCache(namespace string, config ResponseCacheConfig) (*ResponseCache, error) {
+	if len(namespace) == 0 {
+		return nil, fmt.Errorf("namespace cannot be empty")
+	}
+	if config.TTL < 0 || config.StaleWhileRevalidate < 0 || config.MaxEntries < 0 {
+		return nil, fmt.Errorf("the settings of the response cache cannot be negative")
+	}
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.TTL == 0 {
+		config.TTL = time.Minute
+	}
+	if config.MaxEntries == 0 {
+		config.MaxEntries = 1000
+	}
+	return &ResponseCache{
+		config:  config,
+		entries: make(map[string]*cacheEntry),
+		lru:     list.New(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_response_cache_request_total",
+			Help:      "Total of HTTP requests that went through the response cache",
+		}, []string{labelResult}),
+		now: time.Now,
+	}, nil
+}
+
+func (r *ResponseCache) Collect(ch chan<- prometheus.Metric) {
+	r.requests.Collect(ch)
+}
+
+func (r *ResponseCache) Describe(ch chan<- *prometheus.Desc) {
+	r.requests.Describe(ch)
+}
+
+// Invalidate removes the responses of the route (as registered in echo, e.g. /api/projects/:project).
+func (r *ResponseCache) Invalidate(route string) {
+	r.invalidate(func(e *cacheEntry) bool { return e.route == route })
+}
+
+// InvalidateTenant removes the responses of the tenant.
+func (r *ResponseCache) InvalidateTenant(tenant string) {
+	r.invalidate(func(e *cacheEntry) bool { return e.tenant == tenant })
+}
+
+// Purge removes every response.
+func (r *ResponseCache) Purge() {
+	r.invalidate(func(_ *cacheEntry) bool { return true })
+}
+
+func (r *ResponseCache) invalidate(match func(e *cacheEntry) bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, e := range r.entries {
+		if match(e) {
+			r.remove(e)
+		}
+	}
+}
+
+// Process is an echo middleware serving the GET requests from the cache when possible.
+func (r *ResponseCache) Process(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if req.Method != http.MethodGet || r.config.Skipper(c) {
+			return next(c)
+		}
+		tenant, _ := TenantFromContext(req.Context())
+		key := tenant + "\x00" + req.URL.Path + "?" + req.URL.Query().Encode()
+		entry, result := r.lookup(key)
+		switch result {
+		case cacheHit:
+			r.requests.WithLabelValues(cacheHit).Inc()
+			return entry.write(c, "HIT")
+		case cacheStale:
+			r.requests.WithLabelValues(cacheStale).Inc()
+			r.revalidate(c, next, key, tenant)
+			return entry.write(c, "STALE")
+		}
+		r.requests.WithLabelValues(cacheMiss).Inc()
+		c.Response().Header().Set(HeaderXCache, "MISS")
+		return r.record(c, next, key, tenant)
+	}
+}
+
+// lookup returns the entry if it can be served. When it is stale, it is flagged as revalidating, so it is refreshed only once.
+func (r *ResponseCache) lookup(key string) (*cacheEntry, string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	e, ok := r.entries[key]
+	if !ok {
+		return nil, cacheMiss
+	}
+	age := r.now().Sub(e.storedAt)
+	if age < r.config.TTL {
+		r.lru.MoveToFront(e.element)
+		return e, cacheHit
+	}
+	if age < r.config.TTL+r.config.StaleWhileRevalidate {
+		r.lru.MoveToFront(e.element)
+		if e.revalidating {
+			return e, cacheHit
+		}
+		e.revalidating = true
+		return e, cacheStale
+	}
+	r.remove(e)
+	return nil, cacheMiss
+}
+
+// record calls the handler and stores the response if it is successful.
+func (r *ResponseCache) record(c echo.Context, next echo.HandlerFunc, key string, tenant string) error {
+	res := c.Response()
+	writer := &recordingWriter{ResponseWriter: res.Writer}
+	res.Writer = writer
+	defer func() {
+		res.Writer = writer.ResponseWriter
+	}()
+	if err := next(c); err != nil {
+		r.release(key)
+		return err
+	}
+	if res.Status != http.StatusOK || len(res.Header().Values(echo.HeaderSetCookie)) > 0 {
+		r.release(key)
+		return nil
+	}
+	header := replayableHeader(res.Header())
+	header.Del(HeaderXCache)
+	r.store(&cacheEntry{
+		key:      key,
+		route:    c.Path(),
+		tenant:   tenant,
+		status:   res.Status,
+		header:   header,
+		body:     writer.body.Bytes(),
+		storedAt: r.now(),
+	})
+	return nil
+}
+
+// revalidate refreshes the response in the background. As the echo.Context is recycled once the request is processed,
+// a new one is created with a copy of the request.
+func (r *ResponseCache) revalidate(c echo.Context, next echo.HandlerFunc, key string, tenant string) {
+	req := c.Request().Clone(context.WithoutCancel(c.Request().Context()))
+	nc := c.Echo().NewContext(req, &discardWriter{header: make(http.Header)})
+	nc.SetPath(c.Path())
+	nc.SetParamNames(c.ParamNames()...)
+	nc.SetParamValues(c.ParamValues()...)
+	go func() {
+		if err := r.record(nc, next, key, tenant); err != nil {
+			LoggerFromContext(req.Context()).WithError(err).Debug("unable to refresh the response in the cache")
+		}
+	}()
+}
+
+// release clears the flag revalidating of the entry when it couldn't be refreshed.
+func (r *ResponseCache) release(key string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if e, ok := r.entries[key]; ok {
+		e.revalidating = false
+	}
+}
+
+func (r *ResponseCache) store(e *cacheEntry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if previous, ok := r.entries[e.key]; ok {
+		r.remove(previous)
+	}
+	e.element = r.lru.PushFront(e)
+	r.entries[e.key] = e
+	for r.lru.Len() > r.config.MaxEntries {
+		r.remove(r.lru.Back().Value.(*cacheEntry))
+	}
+}
+
+func (r *ResponseCache) remove(e *cacheEntry) {
+	r.lru.Remove(e.element)
+	delete(r.entries, e.key)
+}
+
+func (e *cacheEntry) write(c echo.Context, result string) error {
+	header := c.Response().Header()
+	replayHeader(header, e.header)
+	header.Set(HeaderXCache, result)
+	c.Response().WriteHeader(e.status)
+	_, err := c.Response().Write(e.body)
+	return err
+}
+
+// replayableHeader returns a copy of the headers of a response recorded with recordingWriter, so it can be replayed.
+// When a middleware like gzip encodes the response, it wraps the recordingWriter, so the recorded body is not encoded.
+// The headers describing the encoding are then dropped. They are set again by the middleware when the response is replayed,
+// according to what the client accepts. The other values of the Vary header are kept.
+func replayableHeader(header http.Header) http.Header {
+	result := header.Clone()
+	result.Del(echo.HeaderContentEncoding)
+	result.Del(echo.HeaderContentLength)
+	result.Del(echo.HeaderVary)
+	for _, value := range header.Values(echo.HeaderVary) {
+		for _, token := range strings.Split(value, ",") {
+			token = strings.TrimSpace(token)
+			if len(token) > 0 && !strings.EqualFold(token, echo.HeaderAcceptEncoding) {
+				result.Add(echo.HeaderVary, token)
+			}
+		}
+	}
+	return result
+}
+
+// replayHeader copies the headers returned by replayableHeader to the response.
+// The values of the Vary header are added to the ones already set by a middleware like gzip wrapping the replay.
+func replayHeader(dst http.Header, src http.Header) {
+	for k, v := range src {
+		if k == echo.HeaderVary {
+			dst[k] = append(dst[k], v...)
+			continue
+		}
+		dst[k] = append([]string(nil), v...)
+	}
+}
+
+type recordingWriter struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type discardWriter struct {
+	header http.Header
+}
+
+func (d *discardWriter) Header() http.Header {
+	return d.header
+}
+
+func (d *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (d *discardWriter) WriteHeader(_ int) {}
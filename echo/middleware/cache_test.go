@@ -0,0 +1,165 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Add(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func TestResponseCache(t *testing.T) {
+	cache, err := NewResponseCache("test", ResponseCacheConfig{TTL: time.Minute, StaleWhileRevalidate: time.Minute, MaxEntries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cache.now = clock.Now
+	var calls atomic.Int32
+	e := echo.New()
+	e.Use(cache.Process)
+	e.GET("/api/projects/:project", func(c echo.Context) error {
+		n := calls.Add(1)
+		return c.String(http.StatusOK, c.Param("project")+" "+strconv.Itoa(int(n)))
+	})
+	e.GET("/error", func(c echo.Context) error {
+		calls.Add(1)
+		return echo.ErrNotFound
+	})
+	call := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := call("/api/projects/foo?b=2&a=1")
+	assert.Equal(t, "foo 1", rec.Body.String())
+	assert.Equal(t, "MISS", rec.Header().Get(HeaderXCache))
+	// the order of the query parameters doesn't matter
+	rec = call("/api/projects/foo?a=1&b=2")
+	assert.Equal(t, "foo 1", rec.Body.String())
+	assert.Equal(t, "HIT", rec.Header().Get(HeaderXCache))
+	assert.Equal(t, echo.MIMETextPlainCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "bar 2", call("/api/projects/bar").Body.String())
+
+	// the errors are not cached
+	assert.Equal(t, http.StatusNotFound, call("/error").Code)
+	assert.Equal(t, http.StatusNotFound, call("/error").Code)
+	assert.Equal(t, int32(4), calls.Load())
+
+	// the stale response is served while it is refreshed
+	clock.Add(90 * time.Second)
+	rec = call("/api/projects/bar")
+	assert.Equal(t, "bar 2", rec.Body.String())
+	assert.Equal(t, "STALE", rec.Header().Get(HeaderXCache))
+	assert.Eventually(t, func() bool {
+		return call("/api/projects/bar").Body.String() == "bar 5"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the expired responses are not served
+	clock.Add(3 * time.Minute)
+	assert.Equal(t, "foo 6", call("/api/projects/foo?a=1&b=2").Body.String())
+
+	// the least recently used response is evicted
+	call("/api/projects/baz")
+	assert.Equal(t, "MISS", call("/api/projects/bar").Header().Get(HeaderXCache))
+
+	cache.Invalidate("/api/projects/:project")
+	assert.Equal(t, "MISS", call("/api/projects/bar").Header().Get(HeaderXCache))
+	cache.Purge()
+	assert.Equal(t, "MISS", call("/api/projects/bar").Header().Get(HeaderXCache))
+	assert.GreaterOrEqual(t, testutil.ToFloat64(cache.requests.WithLabelValues(cacheHit)), float64(2))
+	assert.Equal(t, float64(1), testutil.ToFloat64(cache.requests.WithLabelValues(cacheStale)))
+}
+
+func TestResponseCache_Gzip(t *testing.T) {
+	cache, err := NewResponseCache("test", ResponseCacheConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := echo.New()
+	// like with the default middleware of the server, gzip wraps the cache
+	e.Use(middleware.Gzip(), cache.Process)
+	e.GET("/api", func(c echo.Context) error {
+		c.Response().Header().Add(echo.HeaderVary, "Accept-Language")
+		return c.String(http.StatusOK, "hello hello")
+	})
+	call := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		if len(acceptEncoding) > 0 {
+			req.Header.Set(echo.HeaderAcceptEncoding, acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	gunzip := func(rec *httptest.ResponseRecorder) string {
+		r, gzipErr := gzip.NewReader(rec.Body)
+		if gzipErr != nil {
+			t.Fatal(gzipErr)
+		}
+		data, gzipErr := io.ReadAll(r)
+		if gzipErr != nil {
+			t.Fatal(gzipErr)
+		}
+		return string(data)
+	}
+
+	rec := call("gzip")
+	assert.Equal(t, "MISS", rec.Header().Get(HeaderXCache))
+	assert.Equal(t, "gzip", rec.Header().Get(echo.HeaderContentEncoding))
+	assert.Equal(t, "hello hello", gunzip(rec))
+
+	// the response cached while compressed is served in clear to a client not accepting gzip
+	rec = call("")
+	assert.Equal(t, "HIT", rec.Header().Get(HeaderXCache))
+	assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+	assert.Equal(t, []string{echo.HeaderAcceptEncoding, "Accept-Language"}, rec.Header().Values(echo.HeaderVary))
+	assert.Equal(t, "hello hello", rec.Body.String())
+
+	// and compressed again to a client accepting it
+	rec = call("gzip")
+	assert.Equal(t, "HIT", rec.Header().Get(HeaderXCache))
+	assert.Equal(t, "gzip", rec.Header().Get(echo.HeaderContentEncoding))
+	assert.Equal(t, []string{echo.HeaderAcceptEncoding, "Accept-Language"}, rec.Header().Values(echo.HeaderVary))
+	assert.Equal(t, "hello hello", gunzip(rec))
+}
@@ -0,0 +1,110 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+type DecompressConfig struct {
+	Skipper middleware.Skipper
+	// MaxSize is the maximum size in bytes of the decompressed body. It protects the server against the compression bombs.
+	// When the limit is reached, reading the body fails with the error echo.ErrStatusRequestEntityTooLarge.
+	MaxSize int64
+}
+
+var defaultDecompressConfig = DecompressConfig{
+	Skipper: middleware.DefaultSkipper,
+	MaxSize: 32 << 20,
+}
+
+// Decompress is a middleware that decompresses transparently the body of the requests encoded with gzip or deflate (see the header Content-Encoding).
+func Decompress() echo.MiddlewareFunc {
+	return DecompressWithConfig(defaultDecompressConfig)
+}
+
+func DecompressWithConfig(config DecompressConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = defaultDecompressConfig.Skipper
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = defaultDecompressConfig.MaxSize
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			req := c.Request()
+			var reader io.ReadCloser
+			var err error
+			switch strings.ToLower(strings.TrimSpace(req.Header.Get(echo.HeaderContentEncoding))) {
+			case "gzip", "x-gzip":
+				reader, err = gzip.NewReader(req.Body)
+			case "deflate":
+				// as described by the RFC 9110, deflate is the zlib format.
+				reader, err = zlib.NewReader(req.Body)
+			default:
+				return next(c)
+			}
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "unable to decompress the body of the request").SetInternal(err)
+			}
+			req.Body = &decompressedBody{
+				reader:   reader,
+				original: req.Body,
+				limit:    config.MaxSize,
+			}
+			req.Header.Del(echo.HeaderContentEncoding)
+			req.Header.Del(echo.HeaderContentLength)
+			req.ContentLength = -1
+			return next(c)
+		}
+	}
+}
+
+type decompressedBody struct {
+	reader   io.ReadCloser
+	original io.ReadCloser
+	limit    int64
+	read     int64
+}
+
+func (d *decompressedBody) Read(p []byte) (int, error) {
+	if d.read > d.limit {
+		return 0, echo.ErrStatusRequestEntityTooLarge
+	}
+	// read one byte more than the limit to know if the limit has been exceeded
+	if remaining := d.limit - d.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := d.reader.Read(p)
+	d.read += int64(n)
+	if d.read > d.limit {
+		return n, echo.ErrStatusRequestEntityTooLarge
+	}
+	return n, err
+}
+
+func (d *decompressedBody) Close() error {
+	_ = d.reader.Close()
+	return d.original.Close()
+}
@@ -0,0 +1,174 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+const (
+	HeaderETag        = "ETag"
+	HeaderIfMatch     = "If-Match"
+	HeaderIfNoneMatch = "If-None-Match"
+
+	gzipScheme = "gzip"
+)
+
+type ETagConfig struct {
+	// Skipper must be used to skip the streamed responses, as the middleware needs the whole body to compute the ETag.
+	Skipper middleware.Skipper
+}
+
+var defaultETagConfig = ETagConfig{
+	Skipper: middleware.DefaultSkipper,
+}
+
+// ETag is a middleware setting a strong ETag on the successful JSON responses of the GET and HEAD requests.
+// When the header If-None-Match of the request matches the ETag, the status code 304 is sent without the body.
+// As the middleware gzip compresses the body after the ETag is computed, the content-coding is appended to the ETag of the compressed responses
+// (e.g. "<hash>-gzip"), so the compressed and the uncompressed representations never share the same strong validator (RFC 9110, section 8.8.3).
+func ETag() echo.MiddlewareFunc {
+	return ETagWithConfig(defaultETagConfig)
+}
+
+func ETagWithConfig(config ETagConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = defaultETagConfig.Skipper
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			method := c.Request().Method
+			if (method != http.MethodGet && method != http.MethodHead) || config.Skipper(c) {
+				return next(c)
+			}
+			res := c.Response()
+			writer := &bufferedWriter{ResponseWriter: res.Writer}
+			res.Writer = writer
+			defer func() {
+				res.Writer = writer.ResponseWriter
+			}()
+			err := next(c)
+			if !writer.wroteHeader {
+				// nothing has been written, the error handler will do it
+				return err
+			}
+			if writer.status == http.StatusOK && strings.HasPrefix(res.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+				etag := ComputeETag(writer.body.Bytes())
+				if isGzipped(c) {
+					etag = withContentCoding(etag, gzipScheme)
+				}
+				res.Header().Set(HeaderETag, etag)
+				if matchETag(c.Request().Header.Get(HeaderIfNoneMatch), etag, true) {
+					res.Header().Del(echo.HeaderContentType)
+					res.Header().Del(echo.HeaderContentLength)
+					writer.ResponseWriter.WriteHeader(http.StatusNotModified)
+					res.Status = http.StatusNotModified
+					return err
+				}
+			}
+			writer.ResponseWriter.WriteHeader(writer.status)
+			if _, writeErr := writer.ResponseWriter.Write(writer.body.Bytes()); writeErr != nil && err == nil {
+				err = writeErr
+			}
+			return err
+		}
+	}
+}
+
+// ComputeETag returns the strong ETag of the body.
+func ComputeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// isGzipped returns true if the middleware gzip compresses the response. It sets the header Vary when it is in the chain,
+// and it only compresses the response when the client accepts it.
+func isGzipped(c echo.Context) bool {
+	varyAcceptEncoding := false
+	for _, vary := range c.Response().Header().Values(echo.HeaderVary) {
+		if strings.EqualFold(vary, echo.HeaderAcceptEncoding) {
+			varyAcceptEncoding = true
+			break
+		}
+	}
+	return varyAcceptEncoding && strings.Contains(c.Request().Header.Get(echo.HeaderAcceptEncoding), gzipScheme)
+}
+
+// withContentCoding appends the content-coding to the opaque part of the ETag.
+func withContentCoding(etag string, coding string) string {
+	return strings.TrimSuffix(etag, `"`) + "-" + coding + `"`
+}
+
+// IfMatch checks the header If-Match of the request against the ETag of the current version of the resource,
+// so a modification is only applied on the version the client knows (optimistic concurrency).
+// It returns an HTTP error with the status code 412 when the header is set and doesn't match. The handlers must call it before any modification.
+// The ETag received with a compressed response (see ETag) matches as well, as it describes the same version of the resource.
+func IfMatch(c echo.Context, currentETag string) error {
+	ifMatch := c.Request().Header.Get(HeaderIfMatch)
+	if len(ifMatch) == 0 || matchETag(ifMatch, currentETag, false) || matchETag(ifMatch, withContentCoding(currentETag, gzipScheme), false) {
+		return nil
+	}
+	return echo.NewHTTPError(http.StatusPreconditionFailed, "the resource has been modified in the meantime")
+}
+
+// matchETag returns true if the etag is part of the list of the header. As described by the RFC 9110,
+// If-None-Match uses the weak comparison while If-Match uses the strong one.
+func matchETag(header string, etag string, weak bool) bool {
+	if len(header) == 0 {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedWriter holds the response until the handler is done.
+type bufferedWriter struct {
+	http.ResponseWriter
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.status = code
+	w.wroteHeader = true
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.body.Write(b)
+}
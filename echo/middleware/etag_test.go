@@ -0,0 +1,146 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETag(t *testing.T) {
+	body := map[string]string{"name": "foo"}
+	e := echo.New()
+	e.Use(ETag())
+	e.GET("/json", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, body)
+	})
+	e.GET("/text", func(c echo.Context) error {
+		return c.String(http.StatusOK, "foo")
+	})
+	e.GET("/error", func(_ echo.Context) error {
+		return echo.ErrNotFound
+	})
+	e.PUT("/json", func(c echo.Context) error {
+		if err := IfMatch(c, ComputeETag([]byte(`{"name":"foo"}`+"\n"))); err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+	call := func(method string, path string, header string, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if len(header) > 0 {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call(http.MethodGet, "/json", "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get(HeaderETag)
+	assert.Equal(t, ComputeETag(rec.Body.Bytes()), etag)
+
+	rec = call(http.MethodGet, "/json", HeaderIfNoneMatch, `"other", W/`+etag)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, etag, rec.Header().Get(HeaderETag))
+
+	rec = call(http.MethodGet, "/json", HeaderIfNoneMatch, `"other"`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "{\"name\":\"foo\"}\n", rec.Body.String())
+
+	rec = call(http.MethodGet, "/text", "", "")
+	assert.Equal(t, "foo", rec.Body.String())
+	assert.Empty(t, rec.Header().Get(HeaderETag))
+
+	assert.Equal(t, http.StatusNotFound, call(http.MethodGet, "/error", "", "").Code)
+
+	assert.Equal(t, http.StatusNoContent, call(http.MethodPut, "/json", HeaderIfMatch, etag).Code)
+	assert.Equal(t, http.StatusNoContent, call(http.MethodPut, "/json", "", "").Code)
+	assert.Equal(t, http.StatusPreconditionFailed, call(http.MethodPut, "/json", HeaderIfMatch, `"other"`).Code)
+	assert.Equal(t, http.StatusPreconditionFailed, call(http.MethodPut, "/json", HeaderIfMatch, "W/"+etag).Code)
+}
+
+func TestETag_Head(t *testing.T) {
+	e := echo.New()
+	e.Use(ETag())
+	handler := func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"name": "foo"})
+	}
+	e.GET("/json", handler)
+	e.HEAD("/json", handler)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/json", nil))
+	etag := rec.Header().Get(HeaderETag)
+	assert.NotEmpty(t, etag)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, etag, rec.Header().Get(HeaderETag))
+
+	req := httptest.NewRequest(http.MethodHead, "/json", nil)
+	req.Header.Set(HeaderIfNoneMatch, etag)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+}
+
+func TestETag_Gzip(t *testing.T) {
+	e := echo.New()
+	e.Use(middleware.Gzip(), ETag())
+	e.GET("/json", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"name": "foo"})
+	})
+	e.PUT("/json", func(c echo.Context) error {
+		if err := IfMatch(c, ComputeETag([]byte(`{"name":"foo"}`+"\n"))); err != nil {
+			return err
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+	call := func(method string, acceptEncoding string, header string, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/json", nil)
+		if len(acceptEncoding) > 0 {
+			req.Header.Set(echo.HeaderAcceptEncoding, acceptEncoding)
+		}
+		if len(header) > 0 {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	identityETag := call(http.MethodGet, "", "", "").Header().Get(HeaderETag)
+	rec := call(http.MethodGet, "gzip", "", "")
+	assert.Equal(t, "gzip", rec.Header().Get(echo.HeaderContentEncoding))
+	gzipETag := rec.Header().Get(HeaderETag)
+	assert.Equal(t, strings.TrimSuffix(identityETag, `"`)+`-gzip"`, gzipETag)
+
+	assert.Equal(t, http.StatusNotModified, call(http.MethodGet, "gzip", HeaderIfNoneMatch, gzipETag).Code)
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, "", HeaderIfNoneMatch, gzipETag).Code)
+	assert.Equal(t, http.StatusOK, call(http.MethodGet, "gzip", HeaderIfNoneMatch, identityETag).Code)
+
+	// both ETags describe the same version of the resource
+	assert.Equal(t, http.StatusNoContent, call(http.MethodPut, "", HeaderIfMatch, identityETag).Code)
+	assert.Equal(t, http.StatusNoContent, call(http.MethodPut, "gzip", HeaderIfMatch, gzipETag).Code)
+}
@@ -0,0 +1,243 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed is set on the responses replayed from the IdempotencyStore.
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// IdempotentResponse is what the IdempotencyStore keeps for every key.
+type IdempotentResponse struct {
+	// RequestHash identifies the request that used the key. The key cannot be reused with a different request.
+	RequestHash string `json:"request_hash"`
+	// Status is zero while the request is processed.
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+}
+
+// IdempotencyStore keeps the responses of the requests sent with an Idempotency-Key. It must be shared by every instance of
+// the application when there are several of them. The implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// Reserve stores the response (without status) if the key is not used yet. Otherwise, it returns the stored response and false.
+	Reserve(ctx context.Context, key string, response IdempotentResponse, ttl time.Duration) (*IdempotentResponse, bool, error)
+	// Save replaces the response of the key.
+	Save(ctx context.Context, key string, response IdempotentResponse, ttl time.Duration) error
+	// Delete removes the key, so it can be used again.
+	Delete(ctx context.Context, key string) error
+}
+
+type IdempotencyConfig struct {
+	Skipper middleware.Skipper
+	// Store keeps the responses. By default, they are kept in memory, which is only suitable when there is a single instance of the application.
+	Store IdempotencyStore
+	// TTL is how long a response is kept. By default, it is 24 hours.
+	TTL time.Duration
+	// Required rejects the requests without Idempotency-Key with the status code 400.
+	Required bool
+	// MaxBodySize is the maximum size in bytes of the body of the requests with an Idempotency-Key, as it is read in memory to identify the request.
+	// The larger requests are rejected with the status code 413. By default, it is 1MiB.
+	MaxBodySize int64
+}
+
+// Idempotency is a middleware implementing the Idempotency-Key pattern for the POST requests: when a client retries a request with the same key,
+// the response of the first request is sent again instead of processing the request twice.
+// The keys are scoped by tenant (see Tenancy). The responses in error (status code 5xx or error returned by the handler) are not kept, so the request can be retried.
+// A key reused with a different request is rejected with the status code 422, and a key used while the first request is still processed with the status code 409.
+func Idempotency() echo.MiddlewareFunc {
+	return IdempotencyWithConfig(IdempotencyConfig{})
+}
+
+func IdempotencyWithConfig(config IdempotencyConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.Store == nil {
+		config.Store = NewMemoryIdempotencyStore()
+	}
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = 1 << 20
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Method != http.MethodPost || config.Skipper(c) {
+				return next(c)
+			}
+			idempotencyKey := req.Header.Get(HeaderIdempotencyKey)
+			if len(idempotencyKey) == 0 {
+				if config.Required {
+					return echo.NewHTTPError(http.StatusBadRequest, "the header Idempotency-Key is missing")
+				}
+				return next(c)
+			}
+			requestHash, err := hashRequest(req, config.MaxBodySize)
+			if err != nil {
+				return err
+			}
+			ctx := req.Context()
+			tenant, _ := TenantFromContext(ctx)
+			key := tenant + "\x00" + idempotencyKey
+			stored, reserved, err := config.Store.Reserve(ctx, key, IdempotentResponse{RequestHash: requestHash}, config.TTL)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "unable to check the Idempotency-Key").SetInternal(err)
+			}
+			if !reserved {
+				return replay(c, stored, requestHash)
+			}
+			return record(c, next, config, key, requestHash)
+		}
+	}
+}
+
+// hashRequest returns the hash of the method, the path and the body of the request. The body is restored, so the handler can read it.
+// It fails if the body is larger than maxBodySize.
+func hashRequest(req *http.Request, maxBodySize int64) (string, error) {
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(body)) > maxBodySize {
+		return "", echo.NewHTTPError(http.StatusRequestEntityTooLarge, "the body of a request with an Idempotency-Key is too large")
+	}
+	_ = req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func replay(c echo.Context, stored *IdempotentResponse, requestHash string) error {
+	if stored.RequestHash != requestHash {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "the Idempotency-Key has already been used with a different request")
+	}
+	if stored.Status == 0 {
+		return echo.NewHTTPError(http.StatusConflict, "a request with the same Idempotency-Key is being processed")
+	}
+	header := c.Response().Header()
+	replayHeader(header, stored.Header)
+	header.Set(HeaderIdempotentReplayed, "true")
+	c.Response().WriteHeader(stored.Status)
+	_, err := c.Response().Write(stored.Body)
+	return err
+}
+
+func record(c echo.Context, next echo.HandlerFunc, config IdempotencyConfig, key string, requestHash string) error {
+	res := c.Response()
+	writer := &recordingWriter{ResponseWriter: res.Writer}
+	res.Writer = writer
+	defer func() {
+		res.Writer = writer.ResponseWriter
+	}()
+	// the store must be updated even if the client is gone
+	ctx := context.WithoutCancel(c.Request().Context())
+	logger := LoggerFromContext(ctx)
+	err := next(c)
+	if err != nil || res.Status >= http.StatusInternalServerError {
+		if deleteErr := config.Store.Delete(ctx, key); deleteErr != nil {
+			logger.WithError(deleteErr).Error("unable to release the Idempotency-Key")
+		}
+		return err
+	}
+	response := IdempotentResponse{
+		RequestHash: requestHash,
+		Status:      res.Status,
+		Header:      replayableHeader(res.Header()),
+		Body:        writer.body.Bytes(),
+	}
+	if saveErr := config.Store.Save(ctx, key, response, config.TTL); saveErr != nil {
+		logger.WithError(saveErr).Error("unable to save the response of the Idempotency-Key")
+	}
+	return nil
+}
+
+type memoryIdempotencyEntry struct {
+	response IdempotentResponse
+	expireAt time.Time
+}
+
+type memoryIdempotencyStore struct {
+	mutex     sync.Mutex
+	entries   map[string]*memoryIdempotencyEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryIdempotencyStore returns an IdempotencyStore keeping the responses in memory. The expired responses are removed regularly.
+func NewMemoryIdempotencyStore() IdempotencyStore {
+	return &memoryIdempotencyStore{
+		entries: make(map[string]*memoryIdempotencyEntry),
+		now:     time.Now,
+	}
+}
+
+func (m *memoryIdempotencyStore) Reserve(_ context.Context, key string, response IdempotentResponse, ttl time.Duration) (*IdempotentResponse, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := m.now()
+	m.sweep(now)
+	if e, ok := m.entries[key]; ok && now.Before(e.expireAt) {
+		stored := e.response
+		return &stored, false, nil
+	}
+	m.entries[key] = &memoryIdempotencyEntry{response: response, expireAt: now.Add(ttl)}
+	return nil, true, nil
+}
+
+func (m *memoryIdempotencyStore) Save(_ context.Context, key string, response IdempotentResponse, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.entries[key] = &memoryIdempotencyEntry{response: response, expireAt: m.now().Add(ttl)}
+	return nil
+}
+
+func (m *memoryIdempotencyStore) Delete(_ context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// sweep removes the expired entries, at most once per minute.
+func (m *memoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for key, e := range m.entries {
+		if !now.Before(e.expireAt) {
+			delete(m.entries, key)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	calls := 0
+	started := make(chan struct{})
+	release := make(chan struct{})
+	e := echo.New()
+	e.Use(IdempotencyWithConfig(IdempotencyConfig{TTL: time.Hour}))
+	e.POST("/objects", func(c echo.Context) error {
+		calls++
+		body, _ := io.ReadAll(c.Request().Body)
+		return c.String(http.StatusCreated, string(body)+" "+strconv.Itoa(calls))
+	})
+	e.POST("/failure", func(_ echo.Context) error {
+		calls++
+		return echo.ErrServiceUnavailable
+	})
+	e.POST("/slow", func(c echo.Context) error {
+		close(started)
+		<-release
+		return c.NoContent(http.StatusCreated)
+	})
+	call := func(path string, key string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if len(key) > 0 {
+			req.Header.Set(HeaderIdempotencyKey, key)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call("/objects", "key1", "foo")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "foo 1", rec.Body.String())
+	rec = call("/objects", "key1", "foo")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "foo 1", rec.Body.String())
+	assert.Equal(t, "true", rec.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal(t, echo.MIMETextPlainCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+
+	// the key cannot be reused with another request
+	assert.Equal(t, http.StatusUnprocessableEntity, call("/objects", "key1", "bar").Code)
+	// without key, the request is always processed
+	assert.Equal(t, "foo 2", call("/objects", "", "foo").Body.String())
+	assert.Equal(t, "foo 3", call("/objects", "", "foo").Body.String())
+
+	// the failures are not kept
+	assert.Equal(t, http.StatusServiceUnavailable, call("/failure", "key2", "").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, call("/failure", "key2", "").Code)
+	assert.Equal(t, 5, calls)
+
+	// the key is locked while the first request is processed
+	done := make(chan struct{})
+	go func() {
+		call("/slow", "key3", "")
+		close(done)
+	}()
+	<-started
+	assert.Equal(t, http.StatusConflict, call("/slow", "key3", "").Code)
+	close(release)
+	<-done
+	assert.Equal(t, http.StatusCreated, call("/slow", "key3", "").Code)
+}
+
+func TestIdempotency_Gzip(t *testing.T) {
+	e := echo.New()
+	e.Use(middleware.Gzip(), Idempotency())
+	e.POST("/objects", func(c echo.Context) error {
+		return c.String(http.StatusCreated, "hello hello")
+	})
+	call := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/objects", strings.NewReader("foo"))
+		req.Header.Set(HeaderIdempotencyKey, "key1")
+		if len(acceptEncoding) > 0 {
+			req.Header.Set(echo.HeaderAcceptEncoding, acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call("gzip")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get(echo.HeaderContentEncoding))
+	r, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "hello hello", string(body))
+
+	// the response is replayed in clear to a client not accepting gzip
+	rec = call("")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get(HeaderIdempotentReplayed))
+	assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+	assert.Equal(t, "hello hello", rec.Body.String())
+}
+
+func TestIdempotency_MaxBodySize(t *testing.T) {
+	calls := 0
+	e := echo.New()
+	e.Use(IdempotencyWithConfig(IdempotencyConfig{MaxBodySize: 4}))
+	e.POST("/objects", func(c echo.Context) error {
+		calls++
+		return c.NoContent(http.StatusCreated)
+	})
+	call := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/objects", strings.NewReader(body))
+		req.Header.Set(HeaderIdempotencyKey, body)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusCreated, call("abcd"))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, call("abcde"))
+	assert.Equal(t, 1, calls)
+}
+
+func TestMemoryIdempotencyStore_Expiration(t *testing.T) {
+	store := NewMemoryIdempotencyStore().(*memoryIdempotencyStore)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	_, reserved, err := store.Reserve(context.Background(), "key", IdempotentResponse{RequestHash: "hash"}, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+	stored, reserved, _ := store.Reserve(context.Background(), "key", IdempotentResponse{RequestHash: "other"}, time.Minute)
+	assert.False(t, reserved)
+	assert.Equal(t, "hash", stored.RequestHash)
+
+	now = now.Add(2 * time.Minute)
+	_, reserved, _ = store.Reserve(context.Background(), "key", IdempotentResponse{RequestHash: "other"}, time.Minute)
+	assert.True(t, reserved)
+	now = now.Add(2 * time.Minute)
+	_, _, _ = store.Reserve(context.Background(), "another", IdempotentResponse{}, time.Minute)
+	assert.Len(t, store.entries, 1)
+}
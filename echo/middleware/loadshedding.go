@@ -0,0 +1,282 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	labelReason = "reason"
+
+	reasonGoroutines   = "goroutines"
+	reasonConcurrency  = "concurrency"
+	reasonQueueTimeout = "queue_timeout"
+
+	// adaptiveLimitCooldown is the minimum time between two decreases of the adaptive limit,
+	// so a burst of slow requests doesn't collapse the limit at once.
+	adaptiveLimitCooldown = time.Second
+)
+
+type LoadSheddingConfig struct {
+	Skipper middleware.Skipper
+	// MaxConcurrency is the maximum number of requests processed at the same time. Zero means no limit.
+	MaxConcurrency int
+	// TargetQueueLatency activates the adaptive limit of concurrency when greater than zero.
+	// When the requests wait longer than it for a slot, the limit is lowered by 10% (at most once per second).
+	// It is then raised by one every time as many requests as the limit got a slot in time, up to MaxConcurrency.
+	// It requires MaxConcurrency and must be lower than MaxQueueWait.
+	TargetQueueLatency time.Duration
+	// MinConcurrency is the lowest value of the adaptive limit. By default, it is 1.
+	MinConcurrency int
+	// MaxQueueWait is how long a request can wait for a slot when MaxConcurrency is reached.
+	// When it is zero, the request is rejected immediately.
+	MaxQueueWait time.Duration
+	// MaxGoroutines rejects the requests when the number of goroutines is above it. Zero means no limit.
+	MaxGoroutines int
+	// RetryAfter is the value of the header Retry-After sent with the rejected requests. By default, it is 1 second.
+	RetryAfter time.Duration
+}
+
+// LoadShedder is a middleware that rejects the requests with the status code 429 when the server is overloaded,
+// so the instance degrades gracefully instead of running out of memory.
+// The limit of concurrency can adapt to the time spent by the requests in the queue (see LoadSheddingConfig.TargetQueueLatency).
+// It provides the metrics to monitor the shedding and must be registered in a prometheus.Registerer.
+type LoadShedder struct {
+	config        LoadSheddingConfig
+	slots         chan struct{}
+	limit         *adaptiveLimit
+	retryAfter    string
+	rejected      *prometheus.CounterVec
+	inFlight      prometheus.Gauge
+	queueDuration prometheus.Histogram
+}
+
+func NewLoadShedder(namespace string, config LoadSheddingConfig) (*LoadShedder, error) {
+	if len(namespace) == 0 {
+		return nil, fmt.Errorf("namespace cannot be empty")
+	}
+	if config.MaxConcurrency < 0 || config.MaxGoroutines < 0 || config.MaxQueueWait < 0 || config.TargetQueueLatency < 0 || config.MinConcurrency < 0 {
+		return nil, fmt.Errorf("the limits of the load shedding cannot be negative")
+	}
+	if config.TargetQueueLatency > 0 {
+		if config.MaxConcurrency == 0 {
+			return nil, fmt.Errorf("the adaptive limit requires MaxConcurrency")
+		}
+		if config.TargetQueueLatency >= config.MaxQueueWait {
+			return nil, fmt.Errorf("TargetQueueLatency must be lower than MaxQueueWait")
+		}
+		if config.MinConcurrency == 0 {
+			config.MinConcurrency = 1
+		}
+		if config.MinConcurrency > config.MaxConcurrency {
+			return nil, fmt.Errorf("MinConcurrency cannot be greater than MaxConcurrency")
+		}
+	}
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = time.Second
+	}
+	l := &LoadShedder{
+		config:     config,
+		retryAfter: strconv.Itoa(int(max(config.RetryAfter.Seconds(), 1))),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_request_shed_total",
+			Help:      "Total of HTTP requests rejected because the server is overloaded",
+		}, []string{labelReason}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_request_in_flight",
+			Help:      "Number of HTTP requests currently processed",
+		}),
+		queueDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_queue_duration_second",
+			Help:      "Time spent by the HTTP requests waiting for a slot to be processed",
+			Buckets:   []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+		}),
+	}
+	if config.MaxConcurrency > 0 {
+		l.slots = make(chan struct{}, config.MaxConcurrency)
+	}
+	if config.TargetQueueLatency > 0 {
+		l.limit = &adaptiveLimit{
+			slots:    l.slots,
+			target:   config.TargetQueueLatency,
+			min:      config.MinConcurrency,
+			max:      config.MaxConcurrency,
+			value:    config.MaxConcurrency,
+			cooldown: adaptiveLimitCooldown,
+			gauge: prometheus.NewGauge(prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "http_request_concurrency_limit",
+				Help:      "Current limit of HTTP requests processed at the same time, adapted to the time spent in the queue",
+			}),
+		}
+		l.limit.gauge.Set(float64(config.MaxConcurrency))
+	}
+	return l, nil
+}
+
+func (l *LoadShedder) Collect(ch chan<- prometheus.Metric) {
+	l.rejected.Collect(ch)
+	l.inFlight.Collect(ch)
+	l.queueDuration.Collect(ch)
+	if l.limit != nil {
+		l.limit.gauge.Collect(ch)
+	}
+}
+
+func (l *LoadShedder) Describe(ch chan<- *prometheus.Desc) {
+	l.rejected.Describe(ch)
+	l.inFlight.Describe(ch)
+	l.queueDuration.Describe(ch)
+	if l.limit != nil {
+		l.limit.gauge.Describe(ch)
+	}
+}
+
+// Process is an echo middleware rejecting the requests when one of the limits is exceeded.
+func (l *LoadShedder) Process(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if l.config.Skipper(c) {
+			return next(c)
+		}
+		if l.config.MaxGoroutines > 0 && runtime.NumGoroutine() > l.config.MaxGoroutines {
+			return l.reject(c, reasonGoroutines)
+		}
+		if l.slots != nil {
+			if err := l.acquire(c); err != nil {
+				return err
+			}
+			defer l.release()
+		}
+		l.inFlight.Inc()
+		defer l.inFlight.Dec()
+		return next(c)
+	}
+}
+
+func (l *LoadShedder) acquire(c echo.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+		l.observeQueue(0)
+		return nil
+	default:
+	}
+	if l.config.MaxQueueWait == 0 {
+		return l.reject(c, reasonConcurrency)
+	}
+	start := time.Now()
+	timer := time.NewTimer(l.config.MaxQueueWait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		l.observeQueue(time.Since(start))
+		return nil
+	case <-timer.C:
+		l.observeQueue(time.Since(start))
+		return l.reject(c, reasonQueueTimeout)
+	case <-c.Request().Context().Done():
+		return c.Request().Context().Err()
+	}
+}
+
+func (l *LoadShedder) observeQueue(wait time.Duration) {
+	l.queueDuration.Observe(wait.Seconds())
+	if l.limit != nil {
+		l.limit.observe(wait)
+	}
+}
+
+func (l *LoadShedder) release() {
+	if l.limit != nil {
+		l.limit.release()
+		return
+	}
+	<-l.slots
+}
+
+func (l *LoadShedder) reject(c echo.Context, reason string) error {
+	l.rejected.WithLabelValues(reason).Inc()
+	c.Response().Header().Set("Retry-After", l.retryAfter)
+	return echo.NewHTTPError(http.StatusTooManyRequests, "the server is overloaded, retry later")
+}
+
+// adaptiveLimit adapts the number of slots to the time spent by the requests in the queue (additive increase, multiplicative decrease).
+// The slots above the limit are kept by the adaptiveLimit when they are released, instead of being given back to the channel.
+type adaptiveLimit struct {
+	mu     sync.Mutex
+	slots  chan struct{}
+	target time.Duration
+	min    int
+	max    int
+	value  int
+	// held is the number of slots kept to enforce the limit. It catches up with max-value as the requests release their slots.
+	held         int
+	successes    int
+	cooldown     time.Duration
+	lastDecrease time.Time
+	gauge        prometheus.Gauge
+}
+
+func (a *adaptiveLimit) observe(wait time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if wait > a.target {
+		a.successes = 0
+		if a.value > a.min && time.Since(a.lastDecrease) >= a.cooldown {
+			a.value = max(a.min, a.value*9/10)
+			a.lastDecrease = time.Now()
+			a.gauge.Set(float64(a.value))
+		}
+		return
+	}
+	if a.value == a.max || time.Since(a.lastDecrease) < a.cooldown {
+		return
+	}
+	a.successes++
+	if a.successes < a.value {
+		return
+	}
+	a.successes = 0
+	a.value++
+	a.gauge.Set(float64(a.value))
+	if a.held > 0 {
+		<-a.slots
+		a.held--
+	}
+}
+
+func (a *adaptiveLimit) release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.held < a.max-a.value {
+		a.held++
+		return
+	}
+	<-a.slots
+}
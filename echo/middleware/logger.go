@@ -17,12 +17,12 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/perses/common/slices"
-	"github.com/sirupsen/logrus"
 )
 
 type LoggerConfig struct {
 	Skipper middleware.Skipper
-	// BlackListEndpoint is the list of endpoint that you don't want to log with the info level
+	// BlackListEndpoint is the list of endpoint that you don't want to log with the info level.
+	// The matching is case-insensitive and only considers the path of the request (the query is ignored).
 	BlackListEndpoint []string
 }
 
@@ -50,14 +50,18 @@ func LoggerWithConfig(config LoggerConfig) echo.MiddlewareFunc {
 			if config.Skipper(c) {
 				return next(c)
 			}
+			reqCtx := c.Request().Context()
 			if err := next(c); err != nil {
 				c.Error(err)
 			}
-			entry := logrus.WithField("method", c.Request().Method).
+			entry := LoggerFromContext(c.Request().Context()).WithField("method", c.Request().Method).
 				WithField("uri", c.Request().RequestURI).
-				WithField("status", c.Response().Status)
+				WithField("status", responseStatus(reqCtx, c))
+			if isAborted(reqCtx) {
+				entry = entry.WithField("aborted", true)
+			}
 
-			if slices.InvertSubContains(config.BlackListEndpoint, c.Request().RequestURI) {
+			if slices.InvertSubContainsFold(config.BlackListEndpoint, c.Request().URL.Path) {
 				entry.Debug()
 			} else {
 				entry.Info()
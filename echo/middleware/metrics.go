@@ -66,9 +66,11 @@ func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
 
 // ProcessHTTPRequest is an echo middleware. It will intercept all responses.
 // It will increase the metrics that count the number of HTTP request and calculate the time took to respond.
+// The requests aborted by the client are counted with the status code StatusClientClosedRequest, and their duration is not observed as it would skew the latencies.
 func (m *Metrics) ProcessHTTPRequest(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		start := time.Now()
+		reqCtx := ctx.Request().Context()
 		if err := next(ctx); err != nil {
 			// Note: if this method is called, the code won't go further.
 			ctx.Error(err)
@@ -88,7 +90,7 @@ func (m *Metrics) ProcessHTTPRequest(next echo.HandlerFunc) echo.HandlerFunc {
 			method = "not_standard"
 		}
 
-		status := strconv.Itoa(ctx.Response().Status)
+		status := strconv.Itoa(responseStatus(reqCtx, ctx))
 		counter, err := m.totalHTTPRequest.GetMetricWith(prometheus.Labels{labelCode: status, labelHandler: ctx.Path(), labelMethod: method})
 		if err != nil {
 			logrus.WithError(err).Error("unable to get the counter metrics in the api monitoring")
@@ -96,6 +98,9 @@ func (m *Metrics) ProcessHTTPRequest(next echo.HandlerFunc) echo.HandlerFunc {
 			return nil
 		}
 		counter.Inc()
+		if status == strconv.Itoa(StatusClientClosedRequest) {
+			return nil
+		}
 		sum, err := m.durationHTTPRequest.GetMetricWith(prometheus.Labels{labelHandler: ctx.Path(), labelMethod: method})
 		if err != nil {
 			logrus.WithError(err).Error("unable to get the summary metrics in the api monitoring")
@@ -0,0 +1,198 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	HeaderXQuotaLimit     = "X-Quota-Limit"
+	HeaderXQuotaRemaining = "X-Quota-Remaining"
+	HeaderXQuotaReset     = "X-Quota-Reset"
+
+	reasonRate = "rate"
+
+	quotaWindow = time.Minute
+)
+
+// QuotaLimits are the limits applied to a tenant. Zero means no limit.
+type QuotaLimits struct {
+	// RequestsPerMinute is the number of requests a tenant can send per minute.
+	RequestsPerMinute int `json:"requests_per_minute,omitempty" yaml:"requests_per_minute,omitempty"`
+	// MaxConcurrency is the number of requests of a tenant that can be processed at the same time.
+	MaxConcurrency int `json:"max_concurrency,omitempty" yaml:"max_concurrency,omitempty"`
+}
+
+func (l *QuotaLimits) Verify() error {
+	if l.RequestsPerMinute < 0 || l.MaxConcurrency < 0 {
+		return fmt.Errorf("the quota limits cannot be negative")
+	}
+	return nil
+}
+
+// QuotaConfig can be part of the configuration of the application, so the limits can be changed without restarting it (see Quota.SetLimits).
+type QuotaConfig struct {
+	// Default is applied to the tenants without specific limits.
+	Default QuotaLimits `json:"default,omitempty" yaml:"default,omitempty"`
+	// Tenants associates a tenant to its own limits.
+	Tenants map[string]QuotaLimits `json:"tenants,omitempty" yaml:"tenants,omitempty"`
+}
+
+func (c *QuotaConfig) Verify() error {
+	if err := c.Default.Verify(); err != nil {
+		return err
+	}
+	for tenant, limits := range c.Tenants {
+		if err := limits.Verify(); err != nil {
+			return fmt.Errorf("tenant %q: %w", tenant, err)
+		}
+	}
+	return nil
+}
+
+// tenantUsage is the consumption of the quota by a tenant. It is protected by the mutex of the Quota.
+// The number of usages kept is bounded by the number of tenants, which is why the allowlist of the middleware Tenancy should be used.
+type tenantUsage struct {
+	windowStart time.Time
+	requests    int
+	inFlight    int
+}
+
+// Quota is a middleware enforcing the limits of every tenant. The tenant is the one stored by the middleware Tenancy, which must be used before.
+// The requests without tenant are not limited. The requests exceeding a limit are rejected with the status code 429.
+// The headers X-Quota-Limit, X-Quota-Remaining and X-Quota-Reset (in seconds) inform the client about its consumption of the requests per minute.
+// It provides the metrics to monitor the rejections and must be registered in a prometheus.Registerer.
+type Quota struct {
+	skipper  middleware.Skipper
+	mutex    sync.Mutex
+	limits   QuotaConfig
+	usages   map[string]*tenantUsage
+	rejected *prometheus.CounterVec
+	now      func() time.Time
+}
+
+func NewQuota(namespace string, skipper middleware.Skipper, limits QuotaConfig) (*Quota, error) {
+	if len(namespace) == 0 {
+		return nil, fmt.Errorf("namespace cannot be empty")
+	}
+	if err := limits.Verify(); err != nil {
+		return nil, err
+	}
+	if skipper == nil {
+		skipper = middleware.DefaultSkipper
+	}
+	return &Quota{
+		skipper: skipper,
+		limits:  limits,
+		usages:  make(map[string]*tenantUsage),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_request_quota_exceeded_total",
+			Help:      "Total of HTTP requests rejected because the tenant exceeded its quota",
+		}, []string{labelTenant, labelReason}),
+		now: time.Now,
+	}, nil
+}
+
+// SetLimits replaces the limits. It can be called at any time, typically in a callback of the config.Resolver (see AddChangeCallback).
+// The consumption of the tenants is kept.
+func (q *Quota) SetLimits(limits QuotaConfig) error {
+	if err := limits.Verify(); err != nil {
+		return err
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.limits = limits
+	return nil
+}
+
+func (q *Quota) Collect(ch chan<- prometheus.Metric) {
+	q.rejected.Collect(ch)
+}
+
+func (q *Quota) Describe(ch chan<- *prometheus.Desc) {
+	q.rejected.Describe(ch)
+}
+
+// Process is an echo middleware rejecting the requests of the tenants exceeding their quota.
+func (q *Quota) Process(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if q.skipper(c) {
+			return next(c)
+		}
+		tenant, ok := TenantFromContext(c.Request().Context())
+		if !ok {
+			return next(c)
+		}
+		if err := q.acquire(c, tenant); err != nil {
+			return err
+		}
+		defer q.release(tenant)
+		return next(c)
+	}
+}
+
+func (q *Quota) acquire(c echo.Context, tenant string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	limits, ok := q.limits.Tenants[tenant]
+	if !ok {
+		limits = q.limits.Default
+	}
+	usage, ok := q.usages[tenant]
+	if !ok {
+		usage = &tenantUsage{}
+		q.usages[tenant] = usage
+	}
+	now := q.now()
+	if now.Sub(usage.windowStart) >= quotaWindow {
+		usage.windowStart = now
+		usage.requests = 0
+	}
+	if limits.RequestsPerMinute > 0 {
+		reset := usage.windowStart.Add(quotaWindow).Sub(now)
+		header := c.Response().Header()
+		header.Set(HeaderXQuotaLimit, strconv.Itoa(limits.RequestsPerMinute))
+		header.Set(HeaderXQuotaReset, strconv.Itoa(int(reset.Seconds())))
+		if usage.requests >= limits.RequestsPerMinute {
+			header.Set(HeaderXQuotaRemaining, "0")
+			header.Set(echo.HeaderRetryAfter, strconv.Itoa(max(int(reset.Seconds()), 1)))
+			q.rejected.WithLabelValues(tenant, reasonRate).Inc()
+			return echo.NewHTTPError(http.StatusTooManyRequests, "request quota exceeded")
+		}
+		header.Set(HeaderXQuotaRemaining, strconv.Itoa(limits.RequestsPerMinute-usage.requests-1))
+	}
+	if limits.MaxConcurrency > 0 && usage.inFlight >= limits.MaxConcurrency {
+		q.rejected.WithLabelValues(tenant, reasonConcurrency).Inc()
+		return echo.NewHTTPError(http.StatusTooManyRequests, "too many concurrent requests")
+	}
+	usage.requests++
+	usage.inFlight++
+	return nil
+}
+
+func (q *Quota) release(tenant string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.usages[tenant].inFlight--
+}
@@ -0,0 +1,135 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuota(t *testing.T) {
+	tenancy, err := NewTenancy("test", TenancyConfig{Sources: []TenantSource{TenantFromHeader("X-Tenant")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	quota, err := NewQuota("test", nil, QuotaConfig{
+		Default: QuotaLimits{RequestsPerMinute: 2},
+		Tenants: map[string]QuotaLimits{"unlimited": {}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	quota.now = func() time.Time { return now }
+	e := echo.New()
+	e.Use(tenancy.Process, quota.Process)
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	call := func(tenant string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if len(tenant) > 0 {
+			req.Header.Set("X-Tenant", tenant)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call("foo")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get(HeaderXQuotaLimit))
+	assert.Equal(t, "1", rec.Header().Get(HeaderXQuotaRemaining))
+	assert.Equal(t, "60", rec.Header().Get(HeaderXQuotaReset))
+	now = now.Add(20 * time.Second)
+	rec = call("foo")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get(HeaderXQuotaRemaining))
+	assert.Equal(t, "40", rec.Header().Get(HeaderXQuotaReset))
+	rec = call("foo")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "40", rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Equal(t, float64(1), testutil.ToFloat64(quota.rejected.WithLabelValues("foo", reasonRate)))
+
+	// the other tenants are not impacted
+	assert.Equal(t, http.StatusOK, call("bar").Code)
+	for i := 0; i < 5; i++ {
+		rec = call("unlimited")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get(HeaderXQuotaLimit))
+		assert.Equal(t, http.StatusOK, call("").Code)
+	}
+
+	// a new window starts
+	now = now.Add(40 * time.Second)
+	assert.Equal(t, http.StatusOK, call("foo").Code)
+
+	// the limits are reloaded
+	assert.NoError(t, quota.SetLimits(QuotaConfig{Default: QuotaLimits{RequestsPerMinute: 10}}))
+	rec = call("foo")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "8", rec.Header().Get(HeaderXQuotaRemaining))
+	assert.Error(t, quota.SetLimits(QuotaConfig{Default: QuotaLimits{MaxConcurrency: -1}}))
+}
+
+func TestQuota_Concurrency(t *testing.T) {
+	tenancy, err := NewTenancy("test", TenancyConfig{Sources: []TenantSource{TenantFromHeader("X-Tenant")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	quota, err := NewQuota("test", nil, QuotaConfig{Default: QuotaLimits{MaxConcurrency: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	e := echo.New()
+	e.Use(tenancy.Process, quota.Process)
+	e.GET("/slow", func(c echo.Context) error {
+		close(started)
+		<-release
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/fast", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	newRequest := func(path string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Tenant", "foo")
+		return req
+	}
+
+	done := make(chan struct{})
+	go func() {
+		e.ServeHTTP(httptest.NewRecorder(), newRequest("/slow"))
+		close(done)
+	}()
+	<-started
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, newRequest("/fast"))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, float64(1), testutil.ToFloat64(quota.rejected.WithLabelValues("foo", reasonConcurrency)))
+
+	close(release)
+	<-done
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, newRequest("/fast"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
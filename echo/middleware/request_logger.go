@@ -0,0 +1,66 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type loggerKey struct{}
+
+// RequestLogger is a middleware that injects in the context of the request a logrus.Entry with the fields request_id, route and trace_id (when the request is traced).
+// The handlers can then retrieve it with LoggerFromContext, so every log of a request can be correlated.
+// The request ID is taken from the header X-Request-Id. If it is absent, a new one is generated and set in the response.
+func RequestLogger() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			requestID := req.Header.Get(echo.HeaderXRequestID)
+			if len(requestID) == 0 {
+				requestID = c.Response().Header().Get(echo.HeaderXRequestID)
+			}
+			if len(requestID) == 0 {
+				requestID = generateRequestID()
+				c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+			}
+			entry := logrus.WithField("request_id", requestID).WithField("route", c.Path())
+			if spanContext := trace.SpanContextFromContext(req.Context()); spanContext.HasTraceID() {
+				entry = entry.WithField("trace_id", spanContext.TraceID().String())
+			}
+			c.SetRequest(req.WithContext(context.WithValue(req.Context(), loggerKey{}, entry)))
+			return next(c)
+		}
+	}
+}
+
+// LoggerFromContext returns the logrus.Entry injected by the middleware RequestLogger.
+// If there is none, it returns an entry of the standard logger.
+func LoggerFromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+func generateRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
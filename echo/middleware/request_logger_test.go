@@ -0,0 +1,43 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLogger(t *testing.T) {
+	e := echo.New()
+	e.Use(RequestLogger())
+	var fields map[string]interface{}
+	e.GET("/api/:id", func(c echo.Context) error {
+		fields = LoggerFromContext(c.Request().Context()).Data
+		return c.NoContent(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/api/1", nil)
+	req.Header.Set(echo.HeaderXRequestID, "my-id")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "my-id", fields["request_id"])
+	assert.Equal(t, "/api/:id", fields["route"])
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/1", nil))
+	assert.NotEmpty(t, rec.Header().Get(echo.HeaderXRequestID))
+	assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), fields["request_id"])
+}
@@ -0,0 +1,218 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	labelTenant = "tenant"
+
+	reasonMissingTenant    = "missing"
+	reasonTenantNotAllowed = "not_allowed"
+	reasonTenantInvalid    = "invalid"
+)
+
+type tenantKey struct{}
+
+// TenantSource extracts the tenant from the request. It returns an empty string when the request doesn't provide one.
+type TenantSource func(c echo.Context) (string, error)
+
+// TenantFromHeader extracts the tenant from the header of the request.
+func TenantFromHeader(header string) TenantSource {
+	return func(c echo.Context) (string, error) {
+		return c.Request().Header.Get(header), nil
+	}
+}
+
+// TenantFromParam extracts the tenant from a parameter of the path (e.g. "project" for the route /api/projects/:project).
+func TenantFromParam(param string) TenantSource {
+	return func(c echo.Context) (string, error) {
+		return c.Param(param), nil
+	}
+}
+
+// TenantFromClaim extracts the tenant from a claim of the token stored by an authentication middleware in the echo.Context under contextKey
+// (e.g. "user" for the middleware echo-jwt). The value stored can be the claims themselves (a map with string keys)
+// or a struct holding them in a field Claims (like jwt.Token). The claim must be a string.
+// The token must have been verified by the authentication middleware beforehand.
+func TenantFromClaim(contextKey string, claim string) TenantSource {
+	return func(c echo.Context) (string, error) {
+		token := c.Get(contextKey)
+		if token == nil {
+			return "", nil
+		}
+		claims := reflect.Indirect(reflect.ValueOf(token))
+		if claims.Kind() == reflect.Struct {
+			claims = claims.FieldByName("Claims")
+			for claims.Kind() == reflect.Interface || claims.Kind() == reflect.Ptr {
+				claims = claims.Elem()
+			}
+		}
+		if claims.Kind() != reflect.Map || claims.Type().Key().Kind() != reflect.String {
+			return "", fmt.Errorf("unable to find the claims in the token")
+		}
+		value := claims.MapIndex(reflect.ValueOf(claim).Convert(claims.Type().Key()))
+		if !value.IsValid() {
+			return "", nil
+		}
+		tenant, ok := value.Interface().(string)
+		if !ok {
+			return "", fmt.Errorf("the claim %q is not a string", claim)
+		}
+		return tenant, nil
+	}
+}
+
+type TenancyConfig struct {
+	Skipper middleware.Skipper
+	// Sources are tried in order, the first one returning a tenant wins. It cannot be empty.
+	Sources []TenantSource
+	// Allowlist is the list of the tenants accepted. When empty, any tenant is accepted.
+	// Note that the tenants are used as a label of the metrics, so without allowlist, the cardinality of the metrics is not bounded.
+	Allowlist []string
+	// Required rejects the requests without tenant with the status code 400. Otherwise, they are processed without tenant.
+	Required bool
+}
+
+// Tenancy is a middleware extracting the tenant of the request and storing it in the context of the request (see TenantFromContext).
+// When the middleware RequestLogger is used before, the tenant is added as a field of the request logger.
+// It provides the metrics counting the requests per tenant and must be registered in a prometheus.Registerer.
+type Tenancy struct {
+	config    TenancyConfig
+	mutex     sync.RWMutex
+	allowlist map[string]bool
+	requests  *prometheus.CounterVec
+	rejected  *prometheus.CounterVec
+}
+
+func NewTenancy(namespace string, config TenancyConfig) (*Tenancy, error) {
+	if len(namespace) == 0 {
+		return nil, fmt.Errorf("namespace cannot be empty")
+	}
+	if len(config.Sources) == 0 {
+		return nil, fmt.Errorf("at least one source of the tenant must be provided")
+	}
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	t := &Tenancy{
+		config: config,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_request_tenant_total",
+			Help:      "Total of HTTP requests per tenant",
+		}, []string{labelTenant}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_request_tenant_rejected_total",
+			Help:      "Total of HTTP requests rejected because of their tenant",
+		}, []string{labelReason}),
+	}
+	t.SetAllowlist(config.Allowlist)
+	return t, nil
+}
+
+// SetAllowlist replaces the list of the tenants accepted. It can be called at any time, for example when the configuration is reloaded.
+func (t *Tenancy) SetAllowlist(allowlist []string) {
+	var m map[string]bool
+	if len(allowlist) > 0 {
+		m = make(map[string]bool, len(allowlist))
+		for _, tenant := range allowlist {
+			m[tenant] = true
+		}
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.allowlist = m
+}
+
+func (t *Tenancy) isAllowed(tenant string) bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.allowlist == nil || t.allowlist[tenant]
+}
+
+func (t *Tenancy) Collect(ch chan<- prometheus.Metric) {
+	t.requests.Collect(ch)
+	t.rejected.Collect(ch)
+}
+
+func (t *Tenancy) Describe(ch chan<- *prometheus.Desc) {
+	t.requests.Describe(ch)
+	t.rejected.Describe(ch)
+}
+
+// Process is an echo middleware extracting the tenant of the request.
+// The requests with a tenant not allowed are rejected with the status code 403.
+func (t *Tenancy) Process(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if t.config.Skipper(c) {
+			return next(c)
+		}
+		tenant, err := t.extract(c)
+		if err != nil {
+			t.rejected.WithLabelValues(reasonTenantInvalid).Inc()
+			return echo.NewHTTPError(http.StatusBadRequest, "unable to extract the tenant of the request").SetInternal(err)
+		}
+		if len(tenant) == 0 {
+			if t.config.Required {
+				t.rejected.WithLabelValues(reasonMissingTenant).Inc()
+				return echo.NewHTTPError(http.StatusBadRequest, "the tenant of the request is missing")
+			}
+			return next(c)
+		}
+		if !t.isAllowed(tenant) {
+			t.rejected.WithLabelValues(reasonTenantNotAllowed).Inc()
+			return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("tenant %q is not allowed", tenant))
+		}
+		t.requests.WithLabelValues(tenant).Inc()
+		req := c.Request()
+		ctx := context.WithValue(req.Context(), tenantKey{}, tenant)
+		if entry, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+			ctx = context.WithValue(ctx, loggerKey{}, entry.WithField(labelTenant, tenant))
+		}
+		c.SetRequest(req.WithContext(ctx))
+		return next(c)
+	}
+}
+
+func (t *Tenancy) extract(c echo.Context) (string, error) {
+	for _, source := range t.config.Sources {
+		tenant, err := source(c)
+		if err != nil {
+			return "", err
+		}
+		if len(tenant) > 0 {
+			return tenant, nil
+		}
+	}
+	return "", nil
+}
+
+// TenantFromContext returns the tenant stored by the middleware Tenancy.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
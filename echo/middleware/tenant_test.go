@@ -0,0 +1,104 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeToken struct {
+	Claims interface{}
+}
+
+type fakeClaims map[string]interface{}
+
+func TestTenancy(t *testing.T) {
+	tenancy, err := NewTenancy("test", TenancyConfig{
+		Sources: []TenantSource{
+			TenantFromHeader("X-Tenant"),
+			TenantFromParam("project"),
+			TenantFromClaim("user", "tenant"),
+		},
+		Allowlist: []string{"foo", "bar"},
+		Required:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := echo.New()
+	e.Use(RequestLogger())
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if claim := c.Request().Header.Get("X-Claim"); len(claim) > 0 {
+				c.Set("user", &fakeToken{Claims: fakeClaims{"tenant": claim}})
+			}
+			return next(c)
+		}
+	})
+	e.Use(tenancy.Process)
+	handler := func(c echo.Context) error {
+		tenant, _ := TenantFromContext(c.Request().Context())
+		assert.Equal(t, tenant, LoggerFromContext(c.Request().Context()).Data[labelTenant])
+		return c.String(http.StatusOK, tenant)
+	}
+	e.GET("/api/projects/:project", handler)
+	e.GET("/api", handler)
+
+	testSuites := []struct {
+		title          string
+		path           string
+		header         string
+		claim          string
+		expectedCode   int
+		expectedTenant string
+	}{
+		{title: "tenant from header", path: "/api", header: "foo", expectedCode: http.StatusOK, expectedTenant: "foo"},
+		{title: "header wins over the param", path: "/api/projects/bar", header: "foo", expectedCode: http.StatusOK, expectedTenant: "foo"},
+		{title: "tenant from param", path: "/api/projects/bar", expectedCode: http.StatusOK, expectedTenant: "bar"},
+		{title: "tenant from claim", path: "/api", claim: "bar", expectedCode: http.StatusOK, expectedTenant: "bar"},
+		{title: "tenant not allowed", path: "/api/projects/unknown", expectedCode: http.StatusForbidden},
+		{title: "tenant missing", path: "/api", expectedCode: http.StatusBadRequest},
+	}
+	for _, test := range testSuites {
+		t.Run(test.title, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if len(test.header) > 0 {
+				req.Header.Set("X-Tenant", test.header)
+			}
+			if len(test.claim) > 0 {
+				req.Header.Set("X-Claim", test.claim)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, test.expectedCode, rec.Code)
+			if test.expectedCode == http.StatusOK {
+				assert.Equal(t, test.expectedTenant, rec.Body.String())
+			}
+		})
+	}
+	assert.Equal(t, float64(2), testutil.ToFloat64(tenancy.requests.WithLabelValues("foo")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(tenancy.rejected.WithLabelValues(reasonTenantNotAllowed)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(tenancy.rejected.WithLabelValues(reasonMissingTenant)))
+
+	tenancy.SetAllowlist(nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/projects/unknown", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,82 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+type TimeoutConfig struct {
+	Skipper middleware.Skipper
+	// Default is the timeout applied to the requests that don't match any prefix. Zero means no timeout.
+	Default time.Duration
+	// Routes associates a prefix of the path of the request to a timeout. A prefix matches the whole segments of the path,
+	// so "/api" matches "/api" and "/api/v1" but not "/apix". When several prefixes match, the longest one wins.
+	// A zero timeout deactivates the timeout for the matching requests.
+	Routes map[string]time.Duration
+}
+
+// TimeoutWithConfig is a middleware setting a deadline on the context of the request according to its path.
+// The handlers must use the context of the request for the timeout to be effective.
+// When the handler returns an error caused by the deadline, the client gets the status code 503.
+func TimeoutWithConfig(config TimeoutConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			timeout := config.timeout(c.Request().URL.Path)
+			if timeout <= 0 {
+				return next(c)
+			}
+			ctx, cancel := context.WithTimeout(c.Request().Context(), timeout)
+			defer cancel()
+			c.SetRequest(c.Request().WithContext(ctx))
+			err := next(c)
+			if err != nil && errors.Is(err, context.DeadlineExceeded) && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return echo.ErrServiceUnavailable.WithInternal(err)
+			}
+			return err
+		}
+	}
+}
+
+func (t TimeoutConfig) timeout(path string) time.Duration {
+	timeout := t.Default
+	longest := -1
+	for prefix, d := range t.Routes {
+		if len(prefix) > longest && matchPrefix(path, prefix) {
+			timeout = d
+			longest = len(prefix)
+		}
+	}
+	return timeout
+}
+
+// matchPrefix returns true when the prefix matches whole segments of the path.
+func matchPrefix(path string, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
@@ -0,0 +1,42 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeoutConfig_Timeout(t *testing.T) {
+	config := TimeoutConfig{
+		Default: 10 * time.Second,
+		Routes: map[string]time.Duration{
+			"/api/v1/export":       5 * time.Minute,
+			"/api/v1/export/quick": time.Minute,
+			"/api/v1/stream":       0,
+			"/metrics/":            time.Second,
+		},
+	}
+	assert.Equal(t, 10*time.Second, config.timeout("/api/v1/projects"))
+	assert.Equal(t, 5*time.Minute, config.timeout("/api/v1/export/dashboards"))
+	assert.Equal(t, time.Minute, config.timeout("/api/v1/export/quick"))
+	assert.Equal(t, time.Duration(0), config.timeout("/api/v1/stream"))
+	// a prefix only matches whole segments of the path
+	assert.Equal(t, 5*time.Minute, config.timeout("/api/v1/export/quickly"))
+	assert.Equal(t, 10*time.Second, config.timeout("/api/v1/exports"))
+	assert.Equal(t, 10*time.Second, config.timeout("/api/v1/streaming/logs"))
+	assert.Equal(t, time.Second, config.timeout("/metrics/prometheus"))
+}
@@ -0,0 +1,125 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// MIMEApplicationProblemJSON is the media type of the errors described by the RFC 7807.
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// Problem is the body of an error as described by the RFC 7807 (https://www.rfc-editor.org/rfc/rfc7807).
+type Problem struct {
+	// Type is a URI identifying the type of problem. "about:blank" means the problem has no additional semantic than the status code.
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance identifies the occurrence of the problem. The request ID is used.
+	Instance string `json:"instance,omitempty"`
+}
+
+// ProblemProvider can be implemented by the errors returned by the handlers to control the Problem sent to the client.
+// Type, Title and Status are filled with a default value when they are empty.
+type ProblemProvider interface {
+	Problem() Problem
+}
+
+// ProblemErrorHandler is an echo.HTTPErrorHandler that replies with an application/problem+json body.
+// It supports the errors implementing ProblemProvider and echo.HTTPError. Any other error is considered as an internal error,
+// and its message is not sent to the client.
+// When the client doesn't accept JSON, it falls back on the default error handler of echo.
+func ProblemErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	if !acceptJSON(c.Request()) {
+		c.Echo().DefaultHTTPErrorHandler(err, c)
+		return
+	}
+	problem := toProblem(err)
+	problem.Instance = requestID(c)
+	if problem.Status >= http.StatusInternalServerError {
+		logrus.WithError(err).Error("error while processing the request")
+	}
+	var sendErr error
+	if c.Request().Method == http.MethodHead {
+		sendErr = c.NoContent(problem.Status)
+	} else {
+		c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+		sendErr = c.JSON(problem.Status, problem)
+	}
+	if sendErr != nil {
+		logrus.WithError(sendErr).Error("unable to send the error to the client")
+	}
+}
+
+func toProblem(err error) Problem {
+	var problem Problem
+	var provider ProblemProvider
+	var httpErr *echo.HTTPError
+	if errors.As(err, &provider) {
+		problem = provider.Problem()
+	} else if errors.As(err, &httpErr) {
+		problem.Status = httpErr.Code
+		if httpErr.Message != nil {
+			problem.Detail = fmt.Sprintf("%v", httpErr.Message)
+		}
+	} else {
+		problem.Status = http.StatusInternalServerError
+	}
+	if problem.Status == 0 {
+		problem.Status = http.StatusInternalServerError
+	}
+	if len(problem.Type) == 0 {
+		problem.Type = "about:blank"
+	}
+	if len(problem.Title) == 0 {
+		problem.Title = http.StatusText(problem.Status)
+	}
+	if problem.Detail == problem.Title {
+		// echo uses the status text as default message, no need to repeat it
+		problem.Detail = ""
+	}
+	return problem
+}
+
+func acceptJSON(req *http.Request) bool {
+	accept := req.Header.Get(echo.HeaderAccept)
+	if len(accept) == 0 {
+		return true
+	}
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(mediaRange, ";")
+		switch strings.TrimSpace(mediaType) {
+		case MIMEApplicationProblemJSON, echo.MIMEApplicationJSON, "application/*", "*/*":
+			return true
+		}
+	}
+	return false
+}
+
+func requestID(c echo.Context) string {
+	if id := c.Response().Header().Get(echo.HeaderXRequestID); len(id) > 0 {
+		return id
+	}
+	return c.Request().Header.Get(echo.HeaderXRequestID)
+}
@@ -0,0 +1,76 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type notFoundError struct{}
+
+func (notFoundError) Error() string {
+	return "dashboard not found"
+}
+
+func (notFoundError) Problem() Problem {
+	return Problem{Type: "https://perses.dev/errors/not-found", Status: http.StatusNotFound, Detail: "dashboard not found"}
+}
+
+func TestProblemErrorHandler(t *testing.T) {
+	testSuites := []struct {
+		title  string
+		err    error
+		code   int
+		result string
+	}{
+		{
+			title:  "echo error",
+			err:    echo.NewHTTPError(http.StatusBadRequest, "invalid name"),
+			code:   http.StatusBadRequest,
+			result: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"invalid name","instance":"my-id"}`,
+		},
+		{
+			title:  "problem provider",
+			err:    notFoundError{},
+			code:   http.StatusNotFound,
+			result: `{"type":"https://perses.dev/errors/not-found","title":"Not Found","status":404,"detail":"dashboard not found","instance":"my-id"}`,
+		},
+		{
+			title:  "internal error",
+			err:    errors.New("connection refused"),
+			code:   http.StatusInternalServerError,
+			result: `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"my-id"}`,
+		},
+	}
+	for _, test := range testSuites {
+		t.Run(test.title, func(t *testing.T) {
+			e := echo.New()
+			e.HTTPErrorHandler = ProblemErrorHandler
+			e.GET("/", func(echo.Context) error { return test.err })
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(echo.HeaderXRequestID, "my-id")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, test.code, rec.Code)
+			assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+			assert.JSONEq(t, test.result, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"flag"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/perses/common/config"
+)
+
+var (
+	// http path for the reload of the config
+	reloadPath string
+)
+
+func init() {
+	flag.StringVar(&reloadPath, "web.reload-path", "/-/reload", "Path under which to expose the endpoint forcing the reload of the config.")
+}
+
+// NewReloadAPI returns the API forcing the reload of the config with a POST request.
+// It replies with the status code 204 when the config has been reloaded, and 500 with the error otherwise.
+// In the latter case, the application keeps the previous config.
+// The endpoint is not authenticated, so it should only be registered on demand (see the flag web.enable-reload of the package app).
+func NewReloadAPI(reloader config.Reloader) Register {
+	return &reloadAPI{reloader: reloader}
+}
+
+type reloadAPI struct {
+	Register
+	reloader config.Reloader
+}
+
+func (r *reloadAPI) RegisterRoute(e *echo.Echo) {
+	e.POST(reloadPath, r.reload)
+}
+
+func (r *reloadAPI) reload(ctx echo.Context) error {
+	if err := r.reloader.Reload(); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error()).SetInternal(err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,67 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type reloaderFunc func() error
+
+func (f reloaderFunc) Reload() error {
+	return f()
+}
+
+func TestReloadAPI(t *testing.T) {
+	testSuites := []struct {
+		title string
+		err   error
+		code  int
+	}{
+		{
+			title: "config reloaded",
+			code:  http.StatusNoContent,
+		},
+		{
+			title: "config invalid",
+			err:   errors.New("name cannot be empty"),
+			code:  http.StatusInternalServerError,
+		},
+	}
+	for _, test := range testSuites {
+		t.Run(test.title, func(t *testing.T) {
+			calls := 0
+			e := echo.New()
+			NewReloadAPI(reloaderFunc(func() error {
+				calls++
+				return test.err
+			})).RegisterRoute(e)
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+			assert.Equal(t, test.code, rec.Code)
+			assert.Equal(t, 1, calls)
+
+			rec = httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/reload", nil))
+			assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	persesMiddleware "github.com/perses/common/echo/middleware"
+)
+
+// SecurityProfile bundles the recommended settings hardening the server (see Builder.SecurityProfile).
+type SecurityProfile int
+
+const (
+	// SecurityProfileOff doesn't change anything to the server. It's the default.
+	SecurityProfileOff SecurityProfile = iota
+	// SecurityProfileDefault adds the common security headers, limits the size of the body to 10M and sets the timeouts reading the requests.
+	SecurityProfileDefault
+	// SecurityProfileStrict is like SecurityProfileDefault but with a restrictive Content-Security-Policy, HSTS, a body limited to 2M,
+	// shorter timeouts and a deadline of 1 minute on the context of the requests.
+	SecurityProfileStrict
+)
+
+type securitySettings struct {
+	headers *middleware.SecureConfig
+	// bodyLimit is the maximum size of the body (like "2M"). Empty means no limit.
+	bodyLimit      string
+	requestTimeout time.Duration
+	// the write timeout is never set, as it would interrupt the long-lived requests.
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	idleTimeout       time.Duration
+}
+
+func (p SecurityProfile) settings() securitySettings {
+	switch p {
+	case SecurityProfileDefault:
+		return securitySettings{
+			headers: &middleware.SecureConfig{
+				XSSProtection:      "1; mode=block",
+				ContentTypeNosniff: "nosniff",
+				XFrameOptions:      "SAMEORIGIN",
+				ReferrerPolicy:     "strict-origin-when-cross-origin",
+			},
+			bodyLimit:         "10M",
+			readHeaderTimeout: 10 * time.Second,
+			idleTimeout:       2 * time.Minute,
+		}
+	case SecurityProfileStrict:
+		return securitySettings{
+			headers: &middleware.SecureConfig{
+				XSSProtection:         "1; mode=block",
+				ContentTypeNosniff:    "nosniff",
+				XFrameOptions:         "DENY",
+				HSTSMaxAge:            int((365 * 24 * time.Hour).Seconds()),
+				ContentSecurityPolicy: "default-src 'self'; frame-ancestors 'none'",
+				ReferrerPolicy:        "no-referrer",
+			},
+			bodyLimit:         "2M",
+			requestTimeout:    time.Minute,
+			readHeaderTimeout: 5 * time.Second,
+			readTimeout:       30 * time.Second,
+			idleTimeout:       time.Minute,
+		}
+	default:
+		return securitySettings{}
+	}
+}
+
+// securityMiddleware returns the middleware of the profile, taking into account the ones overridden in the Builder.
+func (b *Builder) securityMiddleware(settings securitySettings) []echo.MiddlewareFunc {
+	var mdws []echo.MiddlewareFunc
+	headers := settings.headers
+	if b.securityHeaders != nil {
+		headers = b.securityHeaders
+	}
+	if headers != nil {
+		mdws = append(mdws, middleware.SecureWithConfig(*headers))
+	}
+	bodyLimit := settings.bodyLimit
+	if b.bodyLimit != nil {
+		bodyLimit = *b.bodyLimit
+	}
+	if len(bodyLimit) > 0 {
+		mdws = append(mdws, middleware.BodyLimit(bodyLimit))
+	}
+	if b.timeouts == nil && settings.requestTimeout > 0 {
+		mdws = append(mdws, persesMiddleware.TimeoutWithConfig(persesMiddleware.TimeoutConfig{Default: settings.requestTimeout}))
+	}
+	return mdws
+}
+
+func (s securitySettings) applyServer(server *http.Server) {
+	if s.readHeaderTimeout > 0 {
+		server.ReadHeaderTimeout = s.readHeaderTimeout
+	}
+	if s.readTimeout > 0 {
+		server.ReadTimeout = s.readTimeout
+	}
+	if s.idleTimeout > 0 {
+		server.IdleTimeout = s.idleTimeout
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+type uploadAPI struct{}
+
+func (uploadAPI) RegisterRoute(e *echo.Echo) {
+	e.POST("/upload", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+}
+
+func TestBuilder_SecurityProfile(t *testing.T) {
+	bigBody := strings.Repeat("a", 3*1024*1024)
+	testSuites := []struct {
+		title           string
+		builder         func(b *Builder) *Builder
+		frameOptions    string
+		hasCSP          bool
+		uploadCode      int
+		readHeaderLimit time.Duration
+	}{
+		{
+			title:      "off",
+			builder:    func(b *Builder) *Builder { return b },
+			uploadCode: http.StatusNoContent,
+		},
+		{
+			title:           "default",
+			builder:         func(b *Builder) *Builder { return b.SecurityProfile(SecurityProfileDefault) },
+			frameOptions:    "SAMEORIGIN",
+			uploadCode:      http.StatusNoContent,
+			readHeaderLimit: 10 * time.Second,
+		},
+		{
+			title:           "strict",
+			builder:         func(b *Builder) *Builder { return b.SecurityProfile(SecurityProfileStrict) },
+			frameOptions:    "DENY",
+			hasCSP:          true,
+			uploadCode:      http.StatusRequestEntityTooLarge,
+			readHeaderLimit: 5 * time.Second,
+		},
+		{
+			title: "strict with overrides",
+			builder: func(b *Builder) *Builder {
+				return b.SecurityProfile(SecurityProfileStrict).
+					SecurityHeaders(middleware.SecureConfig{XFrameOptions: "SAMEORIGIN"}).
+					BodyLimit("")
+			},
+			frameOptions:    "SAMEORIGIN",
+			uploadCode:      http.StatusNoContent,
+			readHeaderLimit: 5 * time.Second,
+		},
+	}
+	for _, test := range testSuites {
+		t.Run(test.title, func(t *testing.T) {
+			handler, err := test.builder(NewBuilder(":0").
+				PrometheusRegisterer(prometheus.NewRegistry()).
+				APIRegistration(jsonAPI{}).
+				APIRegistration(uploadAPI{})).
+				BuildHandler()
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api", nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, test.frameOptions, rec.Header().Get(echo.HeaderXFrameOptions))
+			assert.Equal(t, test.hasCSP, len(rec.Header().Get(echo.HeaderContentSecurityPolicy)) > 0)
+
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(bigBody)))
+			assert.Equal(t, test.uploadCode, rec.Code)
+
+			assert.Equal(t, test.readHeaderLimit, handler.(*echo.Echo).Server.ReadHeaderTimeout)
+		})
+	}
+}
+
+func TestBuilder_SecurityProfileConflictingMiddleware(t *testing.T) {
+	_, err := NewBuilder(":0").
+		PrometheusRegisterer(prometheus.NewRegistry()).
+		APIRegistration(jsonAPI{}).
+		SecurityProfile(SecurityProfileDefault).
+		Middleware(middleware.Secure()).
+		BuildHandler()
+	assert.EqualError(t, err, "the security headers middleware is used more than once, check the middleware added to the default ones")
+}
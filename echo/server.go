@@ -25,6 +25,8 @@
 //
 // - Register a Middleware.
 //
+// - Harden the server with a SecurityProfile.
+//
 // # Usage
 //
 // Instantiate a simple server task :
@@ -45,7 +47,7 @@
 //
 //	func main() {
 //	    serverTask, err := echo.NewBuilder(addr).
-//	            APIRegistration(echo.NewMetricsAPI(true)).
+//	            APIRegistration(echo.NewMetricsAPI(true, nil, nil)).
 //	            MetricNamespace(metricNamespace).
 //	            Build()
 //	}
@@ -62,8 +64,10 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/perses/common/async"
+	"github.com/perses/common/promhelper"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors/version"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 
 	persesMiddleware "github.com/perses/common/echo/middleware"
@@ -89,6 +93,20 @@ type Builder struct {
 	preMDWs            []echo.MiddlewareFunc
 	gzipSkipper        middleware.Skipper
 	activatePprof      bool
+	problemJSON        bool
+	jsonSerializer     echo.JSONSerializer
+	timeouts           *persesMiddleware.TimeoutConfig
+	metricsErrors      *metricsErrorOptions
+	binder             echo.Binder
+	debugMiddleware    bool
+	securityProfile    SecurityProfile
+	securityHeaders    *middleware.SecureConfig
+	bodyLimit          *string
+}
+
+type metricsErrorOptions struct {
+	handling promhttp.HandlerErrorHandling
+	log      promhttp.Logger
 }
 
 func NewBuilder(addr string) *Builder {
@@ -148,6 +166,73 @@ func (b *Builder) APIRegistration(api Register) *Builder {
 	return b
 }
 
+// ProblemJSONErrors replaces the error handler of echo by ProblemErrorHandler,
+// so the errors are sent to the client as application/problem+json (RFC 7807).
+func (b *Builder) ProblemJSONErrors(activate bool) *Builder {
+	b.problemJSON = activate
+	return b
+}
+
+// JSONSerializer replaces the serializer used by echo to encode and decode JSON (echo.DefaultJSONSerializer relying on encoding/json by default).
+// It can be used to plug a faster library (like jsoniter or go-json) on the APIs where the serialization is a bottleneck.
+func (b *Builder) JSONSerializer(serializer echo.JSONSerializer) *Builder {
+	b.jsonSerializer = serializer
+	return b
+}
+
+// Binder replaces the binder used by echo.Context.Bind (echo.DefaultBinder by default) for every route.
+// The middleware UseBinder can still be used to select a different one for a group of routes. See StrictBinder.
+func (b *Builder) Binder(binder echo.Binder) *Builder {
+	b.binder = binder
+	return b
+}
+
+// Timeouts sets a deadline on the context of every request. routes associates a prefix of the path to a timeout,
+// so the long-running endpoints (like exports) can have a longer timeout than the others. The longest prefix matching wins.
+// defaultTimeout is used for the requests not matching any prefix. See persesMiddleware.TimeoutWithConfig.
+func (b *Builder) Timeouts(defaultTimeout time.Duration, routes map[string]time.Duration) *Builder {
+	b.timeouts = &persesMiddleware.TimeoutConfig{
+		Default: defaultTimeout,
+		Routes:  routes,
+	}
+	return b
+}
+
+// MetricsErrorHandling defines how the metrics endpoint reacts when a collector fails, and where the errors are logged
+// (with logrus when errorLog is nil). It applies to every metrics API registered (see NewMetricsAPI) and takes precedence over MetricsOptions.
+func (b *Builder) MetricsErrorHandling(handling promhttp.HandlerErrorHandling, errorLog promhttp.Logger) *Builder {
+	b.metricsErrors = &metricsErrorOptions{handling: handling, log: errorLog}
+	return b
+}
+
+// DebugMiddleware exposes the middleware executed by the server, in order, on the endpoint /debug/middleware.
+// The middleware chain is also logged at the debug level when the server is initialized.
+func (b *Builder) DebugMiddleware(activate bool) *Builder {
+	b.debugMiddleware = activate
+	return b
+}
+
+// SecurityProfile hardens the server with the recommended security headers, body limit and timeouts (see SecurityProfileDefault and SecurityProfileStrict).
+// Each piece can still be overridden with the methods SecurityHeaders, BodyLimit and Timeouts.
+// The middleware of the profile are executed after the default middleware and before the ones provided by the user.
+func (b *Builder) SecurityProfile(profile SecurityProfile) *Builder {
+	b.securityProfile = profile
+	return b
+}
+
+// SecurityHeaders sets the security headers added to every response, whatever the SecurityProfile is.
+func (b *Builder) SecurityHeaders(config middleware.SecureConfig) *Builder {
+	b.securityHeaders = &config
+	return b
+}
+
+// BodyLimit sets the maximum size of the body of the requests (like "4M"), whatever the SecurityProfile is. An empty limit removes it.
+// The requests with a larger body get the status code 413.
+func (b *Builder) BodyLimit(limit string) *Builder {
+	b.bodyLimit = &limit
+	return b
+}
+
 func (b *Builder) ActivatePprof(activate bool) *Builder {
 	b.activatePprof = activate
 	return b
@@ -173,6 +258,24 @@ func (b *Builder) build() (*server, error) {
 	if len(b.apis) == 0 {
 		return nil, fmt.Errorf("no api registered")
 	}
+	if b.promRegisterer == nil {
+		b.promRegisterer = prometheus.DefaultRegisterer
+	}
+	for _, api := range b.apis {
+		if m, ok := api.(*metrics); ok {
+			m.useRegisterer(b.promRegisterer)
+			if b.metricsErrors != nil {
+				m.opts.ErrorHandling = b.metricsErrors.handling
+				m.opts.ErrorLog = b.metricsErrors.log
+			}
+		}
+	}
+	if b.timeouts != nil {
+		// the timeout is applied after the default middleware and before the ones provided by the user
+		b.mdws = append([]echo.MiddlewareFunc{persesMiddleware.TimeoutWithConfig(*b.timeouts)}, b.mdws...)
+	}
+	security := b.securityProfile.settings()
+	b.mdws = append(b.securityMiddleware(security), b.mdws...)
 	if !b.overrideMiddleware {
 		if b.gzipSkipper == nil {
 			b.gzipSkipper = middleware.DefaultSkipper
@@ -182,6 +285,7 @@ func (b *Builder) build() (*server, error) {
 			// It prints stack trace and handles the control to the centralized HTTPErrorHandler.
 			// More information here: https://echo.labstack.com/middleware/recover
 			middleware.Recover(),
+			persesMiddleware.RequestLogger(),
 			persesMiddleware.Logger(),
 			middleware.GzipWithConfig(
 				middleware.GzipConfig{
@@ -190,24 +294,43 @@ func (b *Builder) build() (*server, error) {
 				},
 			),
 		}
-		if b.promRegisterer == nil {
-			b.promRegisterer = prometheus.DefaultRegisterer
-		}
 		if len(b.metricNamespace) > 0 {
 			metricMiddleware, err := persesMiddleware.NewMetrics(b.metricNamespace)
 			if err != nil {
 				return nil, err
 			}
-			b.promRegisterer.MustRegister(metricMiddleware)
-			b.promRegisterer.MustRegister(version.NewCollector(b.metricNamespace))
-			defaultMiddleware = append(defaultMiddleware, metricMiddleware.ProcessHTTPRequest)
+			// another server with the same namespace may have already registered the metrics in the registerer (e.g. when several servers run in the same binary).
+			// In that case, they are shared.
+			registered, err := promhelper.RegisterOrReuse(b.promRegisterer, metricMiddleware)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := promhelper.RegisterOrReuse(b.promRegisterer, version.NewCollector(b.metricNamespace)); err != nil {
+				return nil, err
+			}
+			defaultMiddleware = append(defaultMiddleware, registered.ProcessHTTPRequest)
 
 		}
 		b.mdws = append(defaultMiddleware, b.mdws...)
 	}
+	if err := newMiddlewareChain(b.preMDWs, b.mdws).validate(); err != nil {
+		return nil, err
+	}
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = hidePort
+	security.applyServer(e.Server)
+	if b.problemJSON {
+		e.HTTPErrorHandler = ProblemErrorHandler
+	}
+	if b.jsonSerializer != nil {
+		e.JSONSerializer = b.jsonSerializer
+	}
+	fallbackBinder := b.binder
+	if fallbackBinder == nil {
+		fallbackBinder = &echo.DefaultBinder{}
+	}
+	e.Binder = &routeBinder{fallback: fallbackBinder}
 	return &server{
 		addr:            b.addr,
 		apis:            b.apis,
@@ -216,6 +339,8 @@ func (b *Builder) build() (*server, error) {
 		preMDWs:         b.preMDWs,
 		shutdownTimeout: 30 * time.Second,
 		activatePprof:   b.activatePprof,
+		debugMiddleware: b.debugMiddleware,
+		longLived:       newLongLivedTracker(),
 	}, nil
 }
 
@@ -228,6 +353,8 @@ type server struct {
 	preMDWs         []echo.MiddlewareFunc
 	shutdownTimeout time.Duration
 	activatePprof   bool
+	debugMiddleware bool
+	longLived       *longLivedTracker
 }
 
 func (s *server) String() string {
@@ -237,18 +364,19 @@ func (s *server) String() string {
 func (s *server) Initialize() error {
 	// init global middleware
 	// Remove trailing slash middleware a trailing slash from the request URI
-	s.e.Pre(middleware.RemoveTrailingSlash())
-	for _, p := range s.preMDWs {
-		s.e.Pre(p)
-	}
-	for _, mdw := range s.mdws {
-		s.e.Use(mdw)
-	}
+	preMDWs := append([]echo.MiddlewareFunc{middleware.RemoveTrailingSlash(), s.longLived.middleware}, s.preMDWs...)
+	chain := newMiddlewareChain(preMDWs, s.mdws)
+	chain.log()
+	s.e.Pre(preMDWs...)
+	s.e.Use(s.mdws...)
 	// register apis
 	for _, a := range s.apis {
 		a.RegisterRoute(s.e)
 	}
 	s.registerPprof()
+	if s.debugMiddleware {
+		chain.registerRoute(s.e)
+	}
 	return nil
 }
 
@@ -284,6 +412,8 @@ func (s *server) Finalize() error {
 	shutdownCtx, shutdownCancelFunc := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	// call shutdownCancelFunc to release the resources in case the task ended before the timeout
 	defer shutdownCancelFunc()
+	// notify the long-lived requests first, so they can end properly before the connections are closed.
+	s.longLived.close(shutdownCtx)
 	if err := s.e.Shutdown(shutdownCtx); err != nil {
 		return fmt.Errorf("server shutdown not properly: %w", err)
 	}
@@ -0,0 +1,189 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+type upperSerializer struct {
+	echo.DefaultJSONSerializer
+}
+
+func (upperSerializer) Serialize(c echo.Context, i interface{}, _ string) error {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	_, err = c.Response().Write([]byte(strings.ToUpper(string(data))))
+	return err
+}
+
+type jsonAPI struct{}
+
+func (jsonAPI) RegisterRoute(e *echo.Echo) {
+	e.GET("/api", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"name": "perses"})
+	})
+}
+
+func TestBuilder_JSONSerializer(t *testing.T) {
+	handler, err := NewBuilder(":0").
+		PrometheusRegisterer(prometheus.NewRegistry()).
+		APIRegistration(jsonAPI{}).
+		JSONSerializer(upperSerializer{}).
+		BuildHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api", nil))
+	assert.Equal(t, `{"NAME":"PERSES"}`, rec.Body.String())
+}
+
+// BenchmarkDefaultJSONSerializer measures the default serializer, so it can be compared with the one provided to the Builder.
+func BenchmarkDefaultJSONSerializer(b *testing.B) {
+	e := echo.New()
+	payload := make([]map[string]interface{}, 100)
+	for i := range payload {
+		payload[i] = map[string]interface{}{"name": "dashboard", "panels": []string{"cpu", "memory", "network"}, "duration": "1h"}
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+		if err := c.JSON(http.StatusOK, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestBuilder_MetricsAPIUsesRegisterer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	handler, err := NewBuilder(":0").
+		MetricNamespace("test").
+		PrometheusRegisterer(registry).
+		APIRegistration(NewMetricsAPI(true, nil, nil)).
+		BuildHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	// the metrics registered by the Builder in its registry are exposed
+	assert.Contains(t, rec.Body.String(), "test_build_info")
+}
+
+func TestMetricsAPIWithOptions(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	handler, err := NewBuilder(":0").
+		PrometheusRegisterer(registry).
+		APIRegistration(NewMetricsAPIWithOptions(MetricsOptions{
+			EnableOpenMetrics: true,
+			BasicAuth:         &MetricsBasicAuth{Username: "prometheus", Password: "secret"},
+		})).
+		BuildHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.SetBasicAuth("prometheus", "secret")
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/openmetrics-text")
+}
+
+type failingCollector struct {
+	desc *prometheus.Desc
+}
+
+func (f failingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- f.desc
+}
+
+func (f failingCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.NewInvalidMetric(f.desc, fmt.Errorf("collect failed"))
+}
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (r *recordingLogger) Println(v ...interface{}) {
+	r.lines = append(r.lines, fmt.Sprint(v...))
+}
+
+func TestBuilder_MetricsErrorHandling(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(failingCollector{desc: prometheus.NewDesc("failing", "always fails", nil, nil)})
+	logger := &recordingLogger{}
+	handler, err := NewBuilder(":0").
+		PrometheusRegisterer(registry).
+		APIRegistration(NewMetricsAPI(true, nil, nil)).
+		MetricsErrorHandling(promhttp.ContinueOnError, logger).
+		BuildHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "promhttp_metric_handler_requests_total")
+	if assert.Len(t, logger.lines, 1) {
+		assert.Contains(t, logger.lines[0], "collect failed")
+	}
+}
+
+func TestBuilder_SameNamespaceTwice(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	for i := 0; i < 2; i++ {
+		handler, err := NewBuilder(":0").
+			MetricNamespace("test").
+			PrometheusRegisterer(registry).
+			APIRegistration(jsonAPI{}).
+			BuildHandler()
+		if err != nil {
+			t.Fatal(err)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api", nil))
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == "test_http_request_total" {
+			// the metrics are shared by the two servers
+			assert.Equal(t, float64(2), family.GetMetric()[0].GetCounter().GetValue())
+			return
+		}
+	}
+	t.Fatal("metric test_http_request_total not found")
+}
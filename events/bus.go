@@ -0,0 +1,227 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package events provides an in-process typed publish/subscribe bus, so the different tasks of an application
+// (the HTTP API, the watchers, the cron jobs...) can communicate without sharing ad-hoc channels.
+//
+// # Usage
+//
+//	bus := events.NewBus[Dashboard]("dashboards", prometheus.DefaultRegisterer)
+//	consumer := events.NewSubscriberTask("dashboard indexer", bus, "updated", 100, func(ctx context.Context, d Dashboard) error {
+//	    return indexer.Index(ctx, d)
+//	})
+//	app.NewRunner().WithTasks(bus, consumer)
+//	// somewhere in the HTTP API
+//	bus.Publish("updated", dashboard)
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/perses/common/async"
+	"github.com/perses/common/promhelper"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// AllTopics can be used to subscribe to the events of every topic.
+const AllTopics = "*"
+
+type metrics struct {
+	published *prometheus.CounterVec
+	delivered *prometheus.CounterVec
+	dropped   *prometheus.CounterVec
+}
+
+func newMetrics(r prometheus.Registerer) *metrics {
+	labels := []string{"bus", "topic"}
+	return &metrics{
+		published: promhelper.RegisterOrReuseOrLog(r, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "events_published_total",
+			Help: "Number of events published on the bus",
+		}, labels)),
+		delivered: promhelper.RegisterOrReuseOrLog(r, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "events_delivered_total",
+			Help: "Number of events delivered to a subscriber",
+		}, labels)),
+		dropped: promhelper.RegisterOrReuseOrLog(r, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "events_dropped_total",
+			Help: "Number of events dropped because the buffer of the subscriber was full",
+		}, labels)),
+	}
+}
+
+// Event is what a subscriber receives.
+type Event[T any] struct {
+	Topic   string
+	Payload T
+}
+
+// Subscription is the link between a subscriber and the Bus. The events are received through the channel returned by C.
+type Subscription[T any] struct {
+	bus   *Bus[T]
+	topic string
+	ch    chan Event[T]
+	// closed is protected by the mutex of the bus
+	closed bool
+}
+
+// C returns the channel where the events are received. It is closed when the subscription is canceled or when the bus is closed.
+func (s *Subscription[T]) C() <-chan Event[T] {
+	return s.ch
+}
+
+// Unsubscribe stops the delivery of the events and closes the channel. It can be called multiple times.
+func (s *Subscription[T]) Unsubscribe() {
+	s.bus.unsubscribe(s)
+}
+
+// Bus dispatches the events published on a topic to every subscriber of this topic.
+// The publication never blocks: when the buffer of a subscriber is full, the event is dropped for this subscriber.
+// The Bus is an async.Task, so when it is run by the app.Runner, the subscriptions are closed when the application stops.
+type Bus[T any] struct {
+	async.Task
+	name          string
+	metrics       *metrics
+	mutex         sync.RWMutex
+	subscriptions map[string][]*Subscription[T]
+	closed        bool
+}
+
+// NewBus creates a Bus. name is used to identify the bus in the metrics and the logs.
+// When r is nil, no metric is exposed.
+func NewBus[T any](name string, r prometheus.Registerer) *Bus[T] {
+	b := &Bus[T]{
+		name:          name,
+		subscriptions: make(map[string][]*Subscription[T]),
+	}
+	if r != nil {
+		b.metrics = newMetrics(r)
+	}
+	return b
+}
+
+// Subscribe returns a Subscription receiving the events published on the topic (or on every topic with AllTopics).
+// bufferSize is the number of events that can be waiting to be consumed before the new ones are dropped.
+// On a closed bus, the channel of the Subscription is already closed.
+func (b *Bus[T]) Subscribe(topic string, bufferSize int) *Subscription[T] {
+	s := &Subscription[T]{
+		bus:   b,
+		topic: topic,
+		ch:    make(chan Event[T], max(bufferSize, 0)),
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		s.closed = true
+		close(s.ch)
+		return s
+	}
+	b.subscriptions[topic] = append(b.subscriptions[topic], s)
+	return s
+}
+
+// Publish sends the payload to every subscriber of the topic. It returns the number of subscribers that received it.
+func (b *Bus[T]) Publish(topic string, payload T) int {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	if b.closed {
+		return 0
+	}
+	if b.metrics != nil {
+		b.metrics.published.WithLabelValues(b.name, topic).Inc()
+	}
+	event := Event[T]{Topic: topic, Payload: payload}
+	delivered := b.deliver(b.subscriptions[topic], event)
+	if topic != AllTopics {
+		delivered += b.deliver(b.subscriptions[AllTopics], event)
+	}
+	return delivered
+}
+
+func (b *Bus[T]) deliver(subscriptions []*Subscription[T], event Event[T]) int {
+	delivered := 0
+	for _, s := range subscriptions {
+		select {
+		case s.ch <- event:
+			delivered++
+			if b.metrics != nil {
+				b.metrics.delivered.WithLabelValues(b.name, event.Topic).Inc()
+			}
+		default:
+			logrus.Debugf("event bus '%s': subscriber of the topic '%s' is too slow, event dropped", b.name, s.topic)
+			if b.metrics != nil {
+				b.metrics.dropped.WithLabelValues(b.name, event.Topic).Inc()
+			}
+		}
+	}
+	return delivered
+}
+
+func (b *Bus[T]) unsubscribe(s *Subscription[T]) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.ch)
+	subscriptions := b.subscriptions[s.topic]
+	for i, sub := range subscriptions {
+		if sub == s {
+			b.subscriptions[s.topic] = append(subscriptions[:i:i], subscriptions[i+1:]...)
+			break
+		}
+	}
+	if len(b.subscriptions[s.topic]) == 0 {
+		delete(b.subscriptions, s.topic)
+	}
+}
+
+// Close closes every subscription. The events published afterward are ignored.
+func (b *Bus[T]) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, subscriptions := range b.subscriptions {
+		for _, s := range subscriptions {
+			s.closed = true
+			close(s.ch)
+		}
+	}
+	b.subscriptions = nil
+}
+
+func (b *Bus[T]) String() string {
+	return "event bus " + b.name
+}
+
+func (b *Bus[T]) Initialize() error {
+	return nil
+}
+
+// Execute is waiting for the application to stop.
+func (b *Bus[T]) Execute(ctx context.Context, _ context.CancelFunc) error {
+	<-ctx.Done()
+	return nil
+}
+
+// Finalize closes the bus, so the subscribers are notified the application is stopping.
+func (b *Bus[T]) Finalize() error {
+	b.Close()
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/perses/common/async/taskhelper"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus_Publish(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	bus := NewBus[string]("test", registry)
+	foo := bus.Subscribe("foo", 1)
+	all := bus.Subscribe(AllTopics, 10)
+
+	assert.Equal(t, 2, bus.Publish("foo", "first"))
+	// the buffer of foo is full
+	assert.Equal(t, 1, bus.Publish("foo", "second"))
+	assert.Equal(t, 1, bus.Publish("bar", "third"))
+
+	assert.Equal(t, Event[string]{Topic: "foo", Payload: "first"}, <-foo.C())
+	var received []string
+	for i := 0; i < 3; i++ {
+		received = append(received, (<-all.C()).Payload)
+	}
+	assert.Equal(t, []string{"first", "second", "third"}, received)
+
+	m := bus.metrics
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.published.WithLabelValues("test", "foo")))
+	assert.Equal(t, float64(3), testutil.ToFloat64(m.delivered.WithLabelValues("test", "foo")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.dropped.WithLabelValues("test", "foo")))
+
+	foo.Unsubscribe()
+	foo.Unsubscribe()
+	_, ok := <-foo.C()
+	assert.False(t, ok)
+	assert.Equal(t, 1, bus.Publish("foo", "fourth"))
+
+	bus.Close()
+	assert.Equal(t, 0, bus.Publish("foo", "fifth"))
+	<-all.C()
+	_, ok = <-all.C()
+	assert.False(t, ok)
+	_, ok = <-bus.Subscribe("foo", 1).C()
+	assert.False(t, ok)
+	all.Unsubscribe()
+}
+
+func TestBus_ConcurrentPublish(t *testing.T) {
+	bus := NewBus[int]("test", nil)
+	sub := bus.Subscribe("foo", 1000)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				bus.Publish("foo", j)
+			}
+		}()
+	}
+	wg.Wait()
+	bus.Close()
+	count := 0
+	for range sub.C() {
+		count++
+	}
+	assert.Equal(t, 1000, count)
+}
+
+func TestSubscriberTask(t *testing.T) {
+	bus := NewBus[string]("test", nil)
+	received := make(chan string, 10)
+	task := NewSubscriberTask("subscriber", bus, "foo", 10, func(_ context.Context, payload string) error {
+		received <- payload
+		if payload == "error" {
+			return fmt.Errorf("failure")
+		}
+		return nil
+	})
+	helper, err := taskhelper.New(task)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	taskhelper.Run(ctx, cancel, helper)
+
+	// wait for the task to be subscribed
+	require.Eventually(t, func() bool {
+		return bus.Publish("foo", "error") == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	bus.Publish("foo", "second")
+	assert.Equal(t, "error", <-received)
+	assert.Equal(t, "second", <-received)
+
+	// closing the bus ends the task
+	bus.Close()
+	select {
+	case <-helper.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscriber task didn't stop")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package events
+
+import (
+	"context"
+
+	"github.com/perses/common/async"
+)
+
+type subscriberTask[T any] struct {
+	async.Task
+	name         string
+	bus          *Bus[T]
+	topic        string
+	bufferSize   int
+	handler      func(ctx context.Context, payload T) error
+	subscription *Subscription[T]
+}
+
+// NewSubscriberTask returns a task consuming the events of the topic with the handler, one after the other.
+// The subscription is created when the task is initialized and canceled when it is finalized.
+// An error returned by the handler is logged and doesn't stop the task.
+func NewSubscriberTask[T any](name string, bus *Bus[T], topic string, bufferSize int, handler func(ctx context.Context, payload T) error) async.Task {
+	return &subscriberTask[T]{
+		name:       name,
+		bus:        bus,
+		topic:      topic,
+		bufferSize: bufferSize,
+		handler:    handler,
+	}
+}
+
+func (s *subscriberTask[T]) String() string {
+	return s.name
+}
+
+func (s *subscriberTask[T]) Initialize() error {
+	s.subscription = s.bus.Subscribe(s.topic, s.bufferSize)
+	return nil
+}
+
+func (s *subscriberTask[T]) Execute(ctx context.Context, _ context.CancelFunc) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-s.subscription.C():
+			if !ok {
+				// the bus is closed
+				return nil
+			}
+			if err := s.handler(ctx, event.Payload); err != nil {
+				async.Logger(ctx).WithError(err).Errorf("unable to handle the event of the topic '%s'", event.Topic)
+			}
+		}
+	}
+}
+
+func (s *subscriberTask[T]) Finalize() error {
+	s.subscription.Unsubscribe()
+	return nil
+}
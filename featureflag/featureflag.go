@@ -0,0 +1,195 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package featureflag provides a registry of boolean feature flags used to gate the rollout of a feature.
+// The flags are declared in the code with a default value and can be overridden by the config.
+// The registry is hot-reloadable through the watch of the config resolver, it is a Prometheus collector exposing
+// the metric feature_flag_enabled{flag="..."} and it can expose the flags on the endpoint /api/flags.
+//
+// # Usage
+//
+//	type Config struct {
+//	    FeatureFlags featureflag.Config `yaml:"feature_flags,omitempty"`
+//	}
+//
+//	flags := featureflag.NewRegistry()
+//	newUI := flags.Declare("new_ui", false, "use the new UI")
+//	config.NewResolver[Config]().
+//	    SetConfigFile("config.yaml").
+//	    AddChangeCallback(func(c *Config) { flags.Update(c.FeatureFlags) }).
+//	    Resolve(&c)
+//	flags.Update(c.FeatureFlags)
+//	prometheus.MustRegister(flags)
+//	runner.HTTPServerBuilder().APIRegistration(flags)
+//
+//	if newUI.Enabled() { ... }
+package featureflag
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const apiPath = "/api/flags"
+
+// Config is the part of the config of the application that overrides the default value of the flags, by name.
+type Config map[string]bool
+
+// Flag is a feature flag declared in a Registry.
+type Flag struct {
+	name         string
+	description  string
+	defaultValue bool
+	registry     *Registry
+}
+
+func (f *Flag) Name() string {
+	return f.name
+}
+
+// Enabled returns the value of the flag from the last config applied, or its default value.
+func (f *Flag) Enabled() bool {
+	return f.registry.IsEnabled(f.name)
+}
+
+// Status describes a flag as exposed by the endpoint /api/flags.
+type Status struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     bool   `json:"default"`
+	Enabled     bool   `json:"enabled"`
+}
+
+type Registry struct {
+	mutex  sync.RWMutex
+	flags  map[string]*Flag
+	values Config
+	desc   *prometheus.Desc
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		flags:  make(map[string]*Flag),
+		values: make(Config),
+		desc: prometheus.NewDesc("feature_flag_enabled",
+			"1 if the feature flag is enabled, 0 otherwise",
+			[]string{"flag"}, nil),
+	}
+}
+
+// Declare adds a flag to the registry. Declaring twice the same name returns the flag already declared.
+func (r *Registry) Declare(name string, defaultValue bool, description string) *Flag {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if f, ok := r.flags[name]; ok {
+		return f
+	}
+	f := &Flag{name: name, description: description, defaultValue: defaultValue, registry: r}
+	r.flags[name] = f
+	return f
+}
+
+// IsEnabled returns the value of the flag. It returns false if the flag is not declared.
+func (r *Registry) IsEnabled(name string) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	f, ok := r.flags[name]
+	if !ok {
+		return false
+	}
+	if value, isSet := r.values[name]; isSet {
+		return value
+	}
+	return f.defaultValue
+}
+
+// Update replaces the values coming from the config. It is meant to be called in the change callback of the config resolver.
+// The flags not declared are ignored with a warning, as they are probably a typo.
+func (r *Registry) Update(cfg Config) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	values := make(Config, len(cfg))
+	for name, value := range cfg {
+		if _, ok := r.flags[name]; !ok {
+			logrus.Warningf("the feature flag %q is unknown, it is ignored", name)
+			continue
+		}
+		if previous := r.isEnabled(name); previous != value {
+			logrus.Infof("the feature flag %q is now %s", name, state(value))
+		}
+		values[name] = value
+	}
+	r.values = values
+}
+
+// isEnabled must be called with the lock held.
+func (r *Registry) isEnabled(name string) bool {
+	if value, isSet := r.values[name]; isSet {
+		return value
+	}
+	return r.flags[name].defaultValue
+}
+
+// List returns the status of every flag sorted by name.
+func (r *Registry) List() []Status {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	result := make([]Status, 0, len(r.flags))
+	for name, f := range r.flags {
+		result = append(result, Status{
+			Name:        name,
+			Description: f.description,
+			Default:     f.defaultValue,
+			Enabled:     r.isEnabled(name),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Describe implements prometheus.Collector.
+func (r *Registry) Describe(ch chan<- *prometheus.Desc) {
+	ch <- r.desc
+}
+
+// Collect implements prometheus.Collector.
+func (r *Registry) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range r.List() {
+		value := 0.0
+		if status.Enabled {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(r.desc, prometheus.GaugeValue, value, status.Name)
+	}
+}
+
+// RegisterRoute exposes the flags on the endpoint /api/flags. It makes the Registry usable with echo.Builder.APIRegistration.
+func (r *Registry) RegisterRoute(e *echo.Echo) {
+	e.GET(apiPath, func(c echo.Context) error {
+		return c.JSON(http.StatusOK, r.List())
+	})
+}
+
+func state(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
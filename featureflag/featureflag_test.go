@@ -0,0 +1,56 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featureflag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	newUI := registry.Declare("new_ui", false, "use the new UI")
+	cache := registry.Declare("cache", true, "")
+	assert.False(t, newUI.Enabled())
+	assert.True(t, cache.Enabled())
+
+	registry.Update(Config{"new_ui": true, "unknown": true})
+	assert.True(t, newUI.Enabled())
+	assert.False(t, registry.IsEnabled("unknown"))
+
+	// the values are replaced, so a flag removed from the config gets back its default value
+	registry.Update(Config{"cache": false})
+	assert.False(t, newUI.Enabled())
+	assert.False(t, cache.Enabled())
+
+	expected := `
+# HELP feature_flag_enabled 1 if the feature flag is enabled, 0 otherwise
+# TYPE feature_flag_enabled gauge
+feature_flag_enabled{flag="cache"} 0
+feature_flag_enabled{flag="new_ui"} 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(registry, strings.NewReader(expected)))
+
+	e := echo.New()
+	registry.RegisterRoute(e)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/flags", nil))
+	assert.JSONEq(t, `[{"name":"cache","default":true,"enabled":false},{"name":"new_ui","description":"use the new UI","default":false,"enabled":false}]`, rec.Body.String())
+}
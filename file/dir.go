@@ -0,0 +1,159 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// WatchDir watches recursively the directory root and calls the callback for every file created, modified or removed in the tree.
+// The directories created after the watcher started are automatically watched.
+// Use Options.Include and Options.Exclude to filter the files reported.
+// Example:
+//
+//	w, err := file.WatchDir("/etc/perses/provisioning", file.Options{Include: []string{"*.yaml", "*.json"}}, func(event file.Event) {
+//		fmt.Printf("%s: %s\n", event.Name, event.Op)
+//	})
+func WatchDir(root string, opts Options, callback func(Event)) (*Watcher, error) {
+	w, err := newWatcher(opts, callback)
+	if err != nil {
+		return nil, err
+	}
+	w.root = filepath.Clean(root)
+	if w.watcher == nil {
+		if _, err = os.Stat(w.root); err != nil {
+			return nil, err
+		}
+		w.snapshot = w.scan()
+		go w.run()
+		return w, nil
+	}
+	w.dirs = make(map[string]struct{})
+	w.handle = w.handleDirEvent
+	if _, err = w.addDir(w.root, false); err != nil {
+		_ = w.watcher.Close()
+		return nil, err
+	}
+	w.initChecksum()
+	go w.run()
+	return w, nil
+}
+
+// addDir watches the directory and all its subdirectories.
+// When reportFiles is true, it returns a Create event for every file found (useful when a directory is created with files already inside).
+func (w *Watcher) addDir(dir string, reportFiles bool) ([]Event, error) {
+	var events []Event
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path != dir && os.IsNotExist(err) {
+				// the file has been removed in the meantime
+				return nil
+			}
+			return err
+		}
+		if path != w.root && w.isExcluded(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			if reportFiles && w.isIncluded(path) {
+				events = append(events, Event{Name: path, Op: Create})
+			}
+			return nil
+		}
+		if addErr := w.watcher.Add(path); addErr != nil {
+			return addErr
+		}
+		w.dirs[path] = struct{}{}
+		return nil
+	})
+	return events, err
+}
+
+func (w *Watcher) removeDir(dir string) {
+	prefix := dir + string(filepath.Separator)
+	for d := range w.dirs {
+		if d == dir || strings.HasPrefix(d, prefix) {
+			// the directory could already be unwatched if it has been removed
+			_ = w.watcher.Remove(d)
+			delete(w.dirs, d)
+		}
+	}
+}
+
+func (w *Watcher) handleDirEvent(event fsnotify.Event) []Event {
+	path := filepath.Clean(event.Name)
+	if w.isExcluded(path) {
+		return nil
+	}
+	if _, isDir := w.dirs[path]; isDir {
+		if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+			w.removeDir(path)
+		}
+		return nil
+	}
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			events, addErr := w.addDir(path, true)
+			if addErr != nil {
+				logrus.WithError(addErr).Errorf("Unable to watch the directory %s", path)
+			}
+			return events
+		}
+	}
+	if !w.isIncluded(path) {
+		return nil
+	}
+	if op := toOp(event); op != 0 {
+		return []Event{{Name: path, Op: op}}
+	}
+	return nil
+}
+
+func (w *Watcher) isIncluded(path string) bool {
+	if len(w.opts.Include) == 0 {
+		return true
+	}
+	return w.match(w.opts.Include, path)
+}
+
+func (w *Watcher) isExcluded(path string) bool {
+	return w.match(w.opts.Exclude, path)
+}
+
+func (w *Watcher) match(patterns []string, path string) bool {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+	name := filepath.Base(path)
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"crypto/sha256"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// poll compares the current state of the files with the previous snapshot and returns the differences.
+func (w *Watcher) poll() []Event {
+	current := w.scan()
+	var events []Event
+	for path, hash := range current {
+		previous, ok := w.snapshot[path]
+		if !ok {
+			events = append(events, Event{Name: path, Op: Create})
+		} else if previous != hash {
+			events = append(events, Event{Name: path, Op: Write})
+		}
+	}
+	for path := range w.snapshot {
+		if _, ok := current[path]; !ok {
+			events = append(events, Event{Name: path, Op: Remove})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Name < events[j].Name
+	})
+	w.snapshot = current
+	return events
+}
+
+// scan returns the hash of the content of every file watched.
+func (w *Watcher) scan() map[string][sha256.Size]byte {
+	result := make(map[string][sha256.Size]byte)
+	if len(w.root) == 0 {
+		if data, err := os.ReadFile(w.filename); err == nil {
+			result[w.filename] = sha256.Sum256(data)
+		}
+		return result
+	}
+	err := filepath.WalkDir(w.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// the file could have been removed in the meantime
+			return nil
+		}
+		if path != w.root && w.isExcluded(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !w.isIncluded(path) {
+			return nil
+		}
+		if data, readErr := os.ReadFile(path); readErr == nil {
+			result[path] = sha256.Sum256(data)
+		}
+		return nil
+	})
+	if err != nil {
+		logrus.WithError(err).Errorf("Unable to scan the directory %s", w.root)
+	}
+	return result
+}
+
+// initChecksum wraps the callback to filter the events that don't change the content of the files when Options.Checksum is true.
+// It must be called before starting the watcher.
+func (w *Watcher) initChecksum() {
+	if !w.opts.Checksum {
+		return
+	}
+	w.snapshot = w.scan()
+	callback := w.callback
+	w.callback = func(event Event) {
+		if w.isContentChanged(event) {
+			callback(event)
+		}
+	}
+}
+
+// isContentChanged returns true if the content of the file concerned by the event is different from the one known.
+func (w *Watcher) isContentChanged(event Event) bool {
+	data, err := os.ReadFile(event.Name)
+	if err != nil {
+		// the file doesn't exist anymore (or cannot be read), it is a change only if it was known before.
+		_, known := w.snapshot[event.Name]
+		delete(w.snapshot, event.Name)
+		return known || event.Op.Has(Remove) || event.Op.Has(Rename)
+	}
+	hash := sha256.Sum256(data)
+	if previous, ok := w.snapshot[event.Name]; ok && previous == hash {
+		return false
+	}
+	w.snapshot[event.Name] = hash
+	return true
+}
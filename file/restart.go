@@ -0,0 +1,106 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	restartInitialBackoff = 100 * time.Millisecond
+	restartMaxBackoff     = 30 * time.Second
+)
+
+func (w *Watcher) isClosed() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// restart replaces the fsnotify watcher after a fatal error and adds again every watchpoint.
+// As some events may have been lost, it returns a Write event for every file watched, so the callback can reload them.
+// It retries with an exponential backoff until it succeeds. It returns false if the Watcher has been closed in the meantime.
+func (w *Watcher) restart(cause error) ([]Event, bool) {
+	logrus.WithError(cause).Errorf("Watcher of %s stopped working, it is going to be re-created", w.name())
+	if w.restarts != nil {
+		w.restarts.Inc()
+	}
+	w.mu.Lock()
+	_ = w.watcher.Close()
+	w.mu.Unlock()
+	backoff := restartInitialBackoff
+	for {
+		events, err := w.recreate()
+		if err == nil {
+			logrus.Infof("Watcher of %s has been re-created", w.name())
+			return events, true
+		}
+		if w.isClosed() {
+			return nil, false
+		}
+		logrus.WithError(err).Errorf("Unable to re-create the watcher of %s, next attempt in %s", w.name(), backoff)
+		select {
+		case <-w.done:
+			return nil, false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, restartMaxBackoff)
+	}
+}
+
+func (w *Watcher) recreate() ([]Event, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w.mu.Lock()
+	if w.isClosed() {
+		w.mu.Unlock()
+		_ = watcher.Close()
+		return nil, errors.New("watcher closed")
+	}
+	w.watcher = watcher
+	w.mu.Unlock()
+
+	if len(w.root) > 0 {
+		w.dirs = make(map[string]struct{})
+		events, addErr := w.addDir(w.root, true)
+		if addErr != nil {
+			_ = watcher.Close()
+			return nil, addErr
+		}
+		for i := range events {
+			events[i].Op = Write
+		}
+		return events, nil
+	}
+	if err = watcher.Add(filepath.Dir(w.filename)); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+	w.targetDir = ""
+	w.resolveSymlink()
+	if len(w.realPath) == 0 {
+		return nil, nil
+	}
+	return []Event{{Name: w.filename, Op: Write}}, nil
+}
@@ -0,0 +1,82 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/perses/common/async"
+	"github.com/sirupsen/logrus"
+)
+
+type watcherTask struct {
+	async.Task
+	name    string
+	create  func() (*Watcher, error)
+	watcher *Watcher
+}
+
+// NewTask returns an async.Task watching the given filename, so the lifecycle of the watcher is handled by the app.Runner.
+// The watcher is created when the task is initialized and closed when the task is finalized.
+// Example:
+//
+//	app.NewRunner().WithTasks(file.NewTask("/etc/perses/config.yaml", file.Options{}, func(event file.Event) {
+//		// reload the config
+//	}))
+func NewTask(filename string, opts Options, callback func(Event)) async.Task {
+	return &watcherTask{
+		name: fmt.Sprintf("file watcher %s", filename),
+		create: func() (*Watcher, error) {
+			return NewWatcherWithOptions(filename, opts, callback)
+		},
+	}
+}
+
+// NewDirTask is the same as NewTask, but it watches recursively the directory root (see WatchDir).
+func NewDirTask(root string, opts Options, callback func(Event)) async.Task {
+	return &watcherTask{
+		name: fmt.Sprintf("directory watcher %s", root),
+		create: func() (*Watcher, error) {
+			return WatchDir(root, opts, callback)
+		},
+	}
+}
+
+func (t *watcherTask) String() string {
+	return t.name
+}
+
+func (t *watcherTask) Initialize() error {
+	w, err := t.create()
+	if err != nil {
+		return err
+	}
+	t.watcher = w
+	return nil
+}
+
+func (t *watcherTask) Execute(ctx context.Context, _ context.CancelFunc) error {
+	// the events are handled by the watcher itself, the task just needs to wait for the end.
+	<-ctx.Done()
+	logrus.Debugf("task '%s' has been canceled", t.String())
+	return nil
+}
+
+func (t *watcherTask) Finalize() error {
+	if t.watcher == nil {
+		return nil
+	}
+	return t.watcher.Close()
+}
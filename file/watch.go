@@ -11,47 +11,414 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package file provides utilities to work with files, like watching a file to be notified when it changes.
 package file
 
 import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/perses/common/promhelper"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
-// Watch watches the given filename and calls the given callback when the file is changed.
+// Op describes the kind of change that happened on the file. Multiple operations can be combined when the events are debounced.
+type Op uint32
+
+const (
+	// Create means the file has been created (or the symlink it relies on is now pointing to a new file).
+	Create Op = 1 << iota
+	// Write means the content of the file has been modified.
+	Write
+	// Remove means the file has been removed.
+	Remove
+	// Rename means the file has been renamed (or moved).
+	Rename
+)
+
+// Has returns true if op contains the operation o.
+func (op Op) Has(o Op) bool {
+	return op&o == o
+}
+
+func (op Op) String() string {
+	var result []string
+	for _, o := range []struct {
+		op   Op
+		name string
+	}{{Create, "CREATE"}, {Write, "WRITE"}, {Remove, "REMOVE"}, {Rename, "RENAME"}} {
+		if op.Has(o.op) {
+			result = append(result, o.name)
+		}
+	}
+	return strings.Join(result, "|")
+}
+
+// Event is what the callback receives when the file changes.
+type Event struct {
+	// Name is the path of the file watched.
+	Name string
+	// Op is the kind of change. When the events are debounced, it contains every operation that happened during the burst.
+	Op Op
+}
+
+// Options allows to fine-tune the Watcher.
+type Options struct {
+	// Debounce is the time to wait after the last event before calling the callback.
+	// The events happening during that time are coalesced into a single event. When zero, the callback is called for every event.
+	// It avoids to read a file that is partially written (e.g. truncated then written).
+	Debounce time.Duration
+	// Include is a list of glob patterns (see filepath.Match) used by WatchDir to select the files to report.
+	// A pattern is matched against the path relative to the root (using "/" as separator) and against the name of the file.
+	// When empty, every file is reported.
+	Include []string
+	// Exclude is a list of glob patterns used by WatchDir to ignore files and directories. It is matched like Include.
+	// An excluded directory is not watched at all.
+	Exclude []string
+	// PollInterval activates the polling mode when greater than zero. Instead of relying on the notifications of the filesystem,
+	// the watcher reads the files periodically and compares the hash of their content.
+	// It should be used when the notifications are unreliable, like with NFS or some container mounts.
+	PollInterval time.Duration
+	// Checksum activates the comparison of the hash of the content of the file before calling the callback.
+	// The callback is then only called when the content actually changed (or when the file is removed).
+	// It is always the case in polling mode.
+	Checksum bool
+	// PrometheusRegisterer is used to expose the metric file_watcher_restarts_total counting the number of times
+	// the watcher had to be re-created after a fatal error (like an overflow of the event queue). When nil, no metric is exposed.
+	PrometheusRegisterer prometheus.Registerer
+}
+
+// Watcher is watching a file (or a directory) and calls a callback when the file changes.
+// It must be closed with the method Close once it is not needed anymore to release the resources.
+//
+// The watcher follows the symlinks, so it supports the way Kubernetes is updating the ConfigMap and the Secret mounted as volume.
+// In that case, the file is a symlink to "..data/<file>" and "..data" is a symlink atomically swapped to a new directory on every update.
+type Watcher struct {
+	// watcher is nil in polling mode. It is replaced when the watcher is restarted, so mu must be held to access it outside the go-routine running the watcher.
+	watcher  *fsnotify.Watcher
+	mu       sync.Mutex
+	restarts prometheus.Counter
+	opts     Options
+	callback func(Event)
+	// handle converts the event received from fsnotify to the events to send to the callback.
+	handle func(fsnotify.Event) []Event
+	// filename is the file watched. Empty when watching a directory.
+	filename string
+	// realPath is the path of the file once every symlink is resolved. It is empty if the file doesn't exist.
+	realPath string
+	// targetDir is the directory of realPath when it is different from the parent directory of filename. It is then also watched.
+	targetDir string
+	// root is the directory watched recursively. Empty when watching a file.
+	root string
+	// dirs is the list of directories watched when watching a directory recursively.
+	dirs map[string]struct{}
+	// snapshot is the hash of every file watched. Only used in polling mode or when Options.Checksum is true.
+	snapshot  map[string][sha256.Size]byte
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func newWatcher(opts Options, callback func(Event)) (*Watcher, error) {
+	w := &Watcher{
+		opts:     opts,
+		callback: callback,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if opts.PrometheusRegisterer != nil {
+		w.restarts = promhelper.RegisterOrReuseOrLog(opts.PrometheusRegisterer, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "file_watcher_restarts_total",
+			Help: "Total number of times a file watcher has been re-created after a fatal error",
+		}))
+	}
+	if opts.PollInterval > 0 {
+		return w, nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w.watcher = watcher
+	return w, nil
+}
+
+// NewWatcher watches the given filename and calls the given callback when the file is changed.
 // The watcher uses the parent directory as a watchpoint to be notified if the file doesn't
 // exist when the watcher is created.
 // Example:
 //
-//		file.Watch("/tmp/test.txt", func() {
-//			fmt.Println("File created or changed")
-//		}
-//	)
-func Watch(filename string, callback func()) error {
-	watcher, err := fsnotify.NewWatcher()
+//	w, err := file.NewWatcher("/tmp/test.txt", func() {
+//		fmt.Println("File created or changed")
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+func NewWatcher(filename string, callback func()) (*Watcher, error) {
+	return NewWatcherWithOptions(filename, Options{}, func(event Event) {
+		if event.Op.Has(Create) || event.Op.Has(Write) {
+			callback()
+		}
+	})
+}
+
+// NewWatcherWithOptions is the same as NewWatcher, but the callback receives the kind of change that happened
+// and the watcher can be fine-tuned with the options.
+func NewWatcherWithOptions(filename string, opts Options, callback func(Event)) (*Watcher, error) {
+	w, err := newWatcher(opts, callback)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	go func() {
-		for {
-			select {
-			case event := <-watcher.Events:
-				// As we are watching the parent directory, we only care
-				// about file creation and changes on the given filename.
-				if (event.Has(fsnotify.Write) || event.Has(fsnotify.Create)) && filepath.Base(event.Name) == filepath.Base(filename) {
-					callback()
+	if w.watcher == nil {
+		w.filename = filepath.Clean(filename)
+		w.snapshot = w.scan()
+		go w.run()
+		return w, nil
+	}
+	// Watch the parent directory of the given filename.
+	// Fix a bug with fsnotify if the file does not exist.
+	if err = w.watcher.Add(filepath.Dir(filename)); err != nil {
+		_ = w.watcher.Close()
+		return nil, err
+	}
+	w.filename = filepath.Clean(filename)
+	w.handle = w.handleFileEvent
+	w.resolveSymlink()
+	w.initChecksum()
+	go w.run()
+	return w, nil
+}
+
+// Watch watches the given filename and calls the given callback when the file is changed.
+//
+// Deprecated: the watcher created cannot be stopped, use NewWatcher instead.
+func Watch(filename string, callback func()) error {
+	_, err := NewWatcher(filename, callback)
+	return err
+}
+
+// Close stops the watcher and waits for the callback in progress (if any) to end.
+// It must not be called from the callback itself. Calling Close multiple times is safe.
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.mu.Lock()
+		if w.watcher != nil {
+			w.closeErr = w.watcher.Close()
+		}
+		w.mu.Unlock()
+		<-w.stopped
+	})
+	return w.closeErr
+}
+
+func (w *Watcher) run() {
+	defer close(w.stopped)
+	d := &debouncer{delay: w.opts.Debounce, callback: w.callback}
+	defer d.stop()
+	var fsEvents <-chan fsnotify.Event
+	var fsErrors <-chan error
+	var pollC <-chan time.Time
+	if w.watcher != nil {
+		fsEvents = w.watcher.Events
+		fsErrors = w.watcher.Errors
+	} else {
+		ticker := time.NewTicker(w.opts.PollInterval)
+		defer ticker.Stop()
+		pollC = ticker.C
+	}
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-d.c:
+			d.flush()
+		case <-pollC:
+			d.push(w.poll())
+		case fsEvent, ok := <-fsEvents:
+			if !ok {
+				if w.isClosed() {
+					return
+				}
+				events, restarted := w.restart(fmt.Errorf("event channel closed unexpectedly"))
+				if !restarted {
+					return
+				}
+				fsEvents, fsErrors = w.watcher.Events, w.watcher.Errors
+				d.push(events)
+				continue
+			}
+			d.push(w.handle(fsEvent))
+		case err, ok := <-fsErrors:
+			if !ok {
+				if w.isClosed() {
+					return
 				}
-			case err := <-watcher.Errors:
+				err = fmt.Errorf("error channel closed unexpectedly")
+			} else if !errors.Is(err, fsnotify.ErrEventOverflow) {
 				if err != nil {
-					logrus.WithError(err).Errorf("Unable to watch the file %s", filename)
+					logrus.WithError(err).Errorf("Unable to watch %s", w.name())
 				}
+				continue
 			}
+			events, restarted := w.restart(err)
+			if !restarted {
+				return
+			}
+			fsEvents, fsErrors = w.watcher.Events, w.watcher.Errors
+			d.push(events)
 		}
-	}()
-	// Watch the parent directory of the given filename.
-	// Fix a bug with fsnotify if the file does not exist.
-	err = watcher.Add(filepath.Dir(filename))
-	return err
+	}
+}
+
+// debouncer is coalescing the events until no new event happened during the delay.
+// It is not thread-safe and must only be used by the go-routine running the watcher.
+type debouncer struct {
+	delay    time.Duration
+	callback func(Event)
+	// pending contains the events waiting for the end of the debounce period, in the order they arrived.
+	pending []Event
+	timer   *time.Timer
+	// c is nil (so never selected) while there is no pending event.
+	c <-chan time.Time
+}
+
+func (d *debouncer) push(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	if d.delay <= 0 {
+		for _, event := range events {
+			d.callback(event)
+		}
+		return
+	}
+	d.pending = coalesce(d.pending, events)
+	if d.timer == nil {
+		d.timer = time.NewTimer(d.delay)
+	} else {
+		if !d.timer.Stop() {
+			// drain the channel in case the timer fired but the value hasn't been consumed yet
+			select {
+			case <-d.timer.C:
+			default:
+			}
+		}
+		d.timer.Reset(d.delay)
+	}
+	d.c = d.timer.C
+}
+
+func (d *debouncer) flush() {
+	d.c = nil
+	for _, event := range d.pending {
+		d.callback(event)
+	}
+	d.pending = nil
+}
+
+func (d *debouncer) stop() {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
+
+func (w *Watcher) name() string {
+	if len(w.root) > 0 {
+		return w.root
+	}
+	return w.filename
+}
+
+// coalesce merges the new events into the pending ones. The operations on the same file are combined.
+func coalesce(pending []Event, events []Event) []Event {
+	for _, event := range events {
+		merged := false
+		for i := range pending {
+			if pending[i].Name == event.Name {
+				pending[i].Op |= event.Op
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			pending = append(pending, event)
+		}
+	}
+	return pending
+}
+
+func toOp(event fsnotify.Event) Op {
+	var op Op
+	if event.Has(fsnotify.Create) {
+		op |= Create
+	}
+	if event.Has(fsnotify.Write) {
+		op |= Write
+	}
+	if event.Has(fsnotify.Remove) {
+		op |= Remove
+	}
+	if event.Has(fsnotify.Rename) {
+		op |= Rename
+	}
+	return op
+}
+
+// handleFileEvent converts the fsnotify event into the operation that happened on the file watched.
+func (w *Watcher) handleFileEvent(event fsnotify.Event) []Event {
+	eventName := filepath.Clean(event.Name)
+	if eventName == w.filename || (len(w.realPath) > 0 && eventName == w.realPath) {
+		w.resolveSymlink()
+		if op := toOp(event); op != 0 {
+			return []Event{{Name: w.filename, Op: op}}
+		}
+		return nil
+	}
+	// As we are watching the parent directory, any other event concerns another file.
+	// But it can be a symlink the file is relying on (like the directory "..data" used by Kubernetes).
+	// So we have to check if the file is now pointing to something else.
+	previousRealPath := w.realPath
+	w.resolveSymlink()
+	if w.realPath == previousRealPath {
+		return nil
+	}
+	if len(w.realPath) == 0 {
+		return []Event{{Name: w.filename, Op: Remove}}
+	}
+	return []Event{{Name: w.filename, Op: Create}}
+}
+
+// resolveSymlink resolves the real path of the file and updates the additional watchpoint if needed.
+func (w *Watcher) resolveSymlink() {
+	realPath, err := filepath.EvalSymlinks(w.filename)
+	if err != nil {
+		// the file doesn't exist (yet), the parent directory is still watched.
+		w.realPath = ""
+		return
+	}
+	w.realPath = filepath.Clean(realPath)
+	targetDir := filepath.Dir(w.realPath)
+	if targetDir == filepath.Dir(w.filename) || targetDir == w.targetDir {
+		return
+	}
+	if len(w.targetDir) > 0 {
+		// the directory could have been removed already, so the error doesn't matter.
+		_ = w.watcher.Remove(w.targetDir)
+		w.targetDir = ""
+	}
+	if err := w.watcher.Add(targetDir); err != nil {
+		logrus.WithError(err).Errorf("Unable to watch the directory %s targeted by the file %s", targetDir, w.filename)
+		return
+	}
+	w.targetDir = targetDir
 }
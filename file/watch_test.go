@@ -0,0 +1,270 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// updateConfigMap simulates the way the kubelet is updating a ConfigMap mounted as a volume.
+func updateConfigMap(t *testing.T, dir string, version string, content string) {
+	dataDir := filepath.Join(dir, "..data_"+version)
+	if err := os.Mkdir(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "config.yaml"), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tmpLink := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(filepath.Base(dataDir), tmpLink); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmpLink, filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcher_ConfigMap(t *testing.T) {
+	dir := t.TempDir()
+	updateConfigMap(t, dir, "1", "v1")
+	filename := filepath.Join(dir, "config.yaml")
+	if err := os.Symlink(filepath.Join("..data", "config.yaml"), filename); err != nil {
+		t.Fatal(err)
+	}
+
+	var count atomic.Int32
+	w, err := NewWatcher(filename, func() {
+		count.Add(1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	updateConfigMap(t, dir, "2", "v2")
+	assert.Eventually(t, func() bool { return count.Load() >= 1 }, time.Second, 10*time.Millisecond)
+	_ = os.RemoveAll(filepath.Join(dir, "..data_1"))
+
+	previous := count.Load()
+	updateConfigMap(t, dir, "3", "v3")
+	assert.Eventually(t, func() bool { return count.Load() > previous }, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, w.Close())
+	previous = count.Load()
+	updateConfigMap(t, dir, "4", "v4")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, previous, count.Load())
+}
+
+func TestWatcher_Debounce(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	events := make(chan Event, 10)
+	w, err := NewWatcherWithOptions(filename, Options{Debounce: 50 * time.Millisecond}, func(event Event) {
+		events <- event
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	for i := 0; i < 3; i++ {
+		if err = os.WriteFile(filename, []byte("content"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case event := <-events:
+		assert.True(t, event.Op.Has(Create))
+		assert.True(t, event.Op.Has(Write))
+		assert.Equal(t, filename, event.Name)
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+
+	if err = os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		assert.Equal(t, Remove, event.Op)
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+	assert.Len(t, events, 0)
+}
+
+func TestWatchDir(t *testing.T) {
+	root := t.TempDir()
+	events := make(chan Event, 10)
+	w, err := WatchDir(root, Options{Include: []string{"*.yaml"}, Exclude: []string{"ignored"}}, func(event Event) {
+		events <- event
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	expectEvent := func(name string, op Op) {
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case event := <-events:
+				// only the yaml files outside the ignored directory must be reported
+				assert.Equal(t, name, event.Name)
+				if event.Op.Has(op) {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("no event %s received for %s", op, name)
+			}
+		}
+	}
+
+	subDir := filepath.Join(root, "sub", "dir")
+	if err = os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// give some time to the watcher to add the new directories
+	time.Sleep(50 * time.Millisecond)
+	if err = os.WriteFile(filepath.Join(subDir, "dashboard.yaml"), []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(filepath.Join(subDir, "dashboard.yaml"), Create)
+
+	if err = os.WriteFile(filepath.Join(subDir, "dashboard.json"), []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Join(root, "ignored"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(root, "ignored", "dashboard.yaml"), []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Remove(filepath.Join(subDir, "dashboard.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(filepath.Join(subDir, "dashboard.yaml"), Remove)
+}
+
+func TestWatcher_Polling(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filename, []byte("v1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan Event, 10)
+	w, err := NewWatcherWithOptions(filename, Options{PollInterval: 10 * time.Millisecond}, func(event Event) {
+		events <- event
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// same content, no event expected
+	if err = os.WriteFile(filename, []byte("v1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, events, 0)
+
+	if err = os.WriteFile(filename, []byte("v2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		assert.Equal(t, Event{Name: filename, Op: Write}, event)
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+}
+
+func TestWatcher_Checksum(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filename, []byte("v1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan Event, 10)
+	w, err := NewWatcherWithOptions(filename, Options{Checksum: true, Debounce: 10 * time.Millisecond}, func(event Event) {
+		events <- event
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// same content, no event expected
+	if err = os.WriteFile(filename, []byte("v1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, events, 0)
+
+	if err = os.WriteFile(filename, []byte("v2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		assert.True(t, event.Op.Has(Write))
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+}
+
+func TestWatcher_Restart(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filename, []byte("v1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	registry := prometheus.NewRegistry()
+	events := make(chan Event, 10)
+	w, err := NewWatcherWithOptions(filename, Options{PrometheusRegisterer: registry}, func(event Event) {
+		events <- event
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// simulate a fatal error by closing the underlying watcher
+	w.mu.Lock()
+	_ = w.watcher.Close()
+	w.mu.Unlock()
+
+	select {
+	case event := <-events:
+		// the event sent once the watcher is re-created
+		assert.Equal(t, Event{Name: filename, Op: Write}, event)
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+	assert.Equal(t, float64(1), testutil.ToFloat64(w.restarts))
+
+	if err = os.WriteFile(filename, []byte("v2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		assert.True(t, event.Op.Has(Write))
+	case <-time.After(time.Second):
+		t.Fatal("no event received after the restart")
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package health provides a registry of health checks. The registry can be exposed through the echo package (see echo.NewHealthAPI)
+// and as a Prometheus collector producing the metric family up{check="..."}.
+//
+// # Usage
+//
+//	registry := health.NewRegistry().
+//	        Register("database", health.CheckerFunc(db.Ping)).
+//	        RegisterWithOptions("downstream", health.NewHTTPChecker(client, "http://downstream/api/health"), health.Options{
+//	            Timeout:       2 * time.Second,
+//	            CacheDuration: 10 * time.Second,
+//	        })
+//	prometheus.MustRegister(registry)
+//	runner.HTTPServerBuilder().APIRegistration(echo.NewHealthAPI(registry))
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	defaultTimeout = 5 * time.Second
+	labelCheck     = "check"
+)
+
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// Checker verifies the health of a component. It returns an error when the component is not healthy.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is an adapter to allow the use of ordinary functions as Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+type Options struct {
+	// Timeout is the maximum duration of the check. By default, it is 5 seconds.
+	Timeout time.Duration
+	// CacheDuration is the duration during which the result of the check is reused instead of running the check again.
+	// It protects the checked component when the health is requested often. By default, the result is not cached.
+	CacheDuration time.Duration
+}
+
+// Result is the result of a single check.
+type Result struct {
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report aggregates the result of every check. The status is up only if every check is up.
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+type check struct {
+	name    string
+	checker Checker
+	opts    Options
+	// mutex is held while the check is running, so concurrent calls share the same result when it is cached.
+	mutex sync.Mutex
+	last  *Result
+}
+
+func (c *check) run(ctx context.Context) Result {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.last != nil && c.opts.CacheDuration > 0 && time.Since(c.last.CheckedAt) < c.opts.CacheDuration {
+		return *c.last
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+	result := Result{Status: StatusUp, CheckedAt: time.Now()}
+	if err := c.checker.Check(checkCtx); err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	// The result is not cached when the caller gave up, as it doesn't reflect the state of the checked component.
+	if ctx.Err() == nil {
+		c.last = &result
+	}
+	return result
+}
+
+type Registry struct {
+	mutex  sync.RWMutex
+	checks map[string]*check
+	upDesc *prometheus.Desc
+}
+
+func NewRegistry() *Registry {
+	return NewRegistryWithNamespace("")
+}
+
+// NewRegistryWithNamespace returns a Registry whose metric is prefixed by the namespace (i.e. <namespace>_up).
+func NewRegistryWithNamespace(namespace string) *Registry {
+	return &Registry{
+		checks: make(map[string]*check),
+		upDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "up"),
+			"Result of the health check (1 = up, 0 = down)",
+			[]string{labelCheck}, nil),
+	}
+}
+
+// Register adds a check with the default options. It replaces any check already registered with the same name.
+func (r *Registry) Register(name string, checker Checker) *Registry {
+	return r.RegisterWithOptions(name, checker, Options{})
+}
+
+// RegisterWithOptions adds a check. It replaces any check already registered with the same name.
+func (r *Registry) RegisterWithOptions(name string, checker Checker, opts Options) *Registry {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.checks[name] = &check{name: name, checker: checker, opts: opts}
+	return r
+}
+
+// Check runs every check concurrently and returns the report.
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mutex.RLock()
+	checks := make([]*check, 0, len(r.checks))
+	for _, c := range r.checks {
+		checks = append(checks, c)
+	}
+	r.mutex.RUnlock()
+
+	results := make([]Result, len(checks))
+	wg := sync.WaitGroup{}
+	wg.Add(len(checks))
+	for i, c := range checks {
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]Result, len(checks))}
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status == StatusDown {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// Describe implements prometheus.Collector.
+func (r *Registry) Describe(ch chan<- *prometheus.Desc) {
+	ch <- r.upDesc
+}
+
+// Collect implements prometheus.Collector. The checks are run when the metrics are collected.
+func (r *Registry) Collect(ch chan<- prometheus.Metric) {
+	report := r.Check(context.Background())
+	names := make([]string, 0, len(report.Checks))
+	for name := range report.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := 0.0
+		if report.Checks[name].Status == StatusUp {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(r.upDesc, prometheus.GaugeValue, value, name)
+	}
+}
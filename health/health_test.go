@@ -0,0 +1,75 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Check(t *testing.T) {
+	var calls atomic.Int32
+	registry := NewRegistry().
+		Register("ok", CheckerFunc(func(_ context.Context) error { return nil })).
+		Register("ko", CheckerFunc(func(_ context.Context) error { return errors.New("unreachable") })).
+		RegisterWithOptions("cached", CheckerFunc(func(_ context.Context) error {
+			calls.Add(1)
+			return nil
+		}), Options{CacheDuration: time.Minute}).
+		RegisterWithOptions("slow", CheckerFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}), Options{Timeout: 10 * time.Millisecond})
+
+	report := registry.Check(context.Background())
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, StatusUp, report.Checks["ok"].Status)
+	assert.Equal(t, "unreachable", report.Checks["ko"].Error)
+	assert.Equal(t, StatusDown, report.Checks["slow"].Status)
+
+	registry.Check(context.Background())
+	assert.Equal(t, int32(1), calls.Load())
+
+	expected := `
+# HELP up Result of the health check (1 = up, 0 = down)
+# TYPE up gauge
+up{check="cached"} 1
+up{check="ko"} 0
+up{check="ok"} 1
+up{check="slow"} 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(registry, strings.NewReader(expected)))
+}
+
+func TestRegistry_CheckCancelled(t *testing.T) {
+	registry := NewRegistry().
+		RegisterWithOptions("cached", CheckerFunc(func(ctx context.Context) error {
+			return ctx.Err()
+		}), Options{CacheDuration: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := registry.Check(ctx)
+	assert.Equal(t, StatusDown, report.Status)
+
+	report = registry.Check(context.Background())
+	assert.Equal(t, StatusUp, report.Status)
+}
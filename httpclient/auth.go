@@ -0,0 +1,209 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/prometheus/common/config"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// AuthConfig describes how the client authenticates itself. Only one method can be set.
+// The secrets can be provided inline or in a file. When they are provided in a file, the file is read on every request,
+// so the secrets can be rotated without restarting the application.
+// The secrets are read with the readers of github.com/prometheus/common/config, like the authentication of the HTTPClientConfig.
+// Use AuthConfig when the transport doesn't come from a HTTPClientConfig (see Builder.Transport), otherwise the HTTPClientConfig can be used directly.
+type AuthConfig struct {
+	BasicAuth   *BasicAuth   `json:"basic_auth,omitempty" yaml:"basic_auth,omitempty"`
+	BearerToken *BearerToken `json:"bearer_token,omitempty" yaml:"bearer_token,omitempty"`
+	OAuth2      *OAuth2      `json:"oauth2,omitempty" yaml:"oauth2,omitempty"`
+}
+
+func (a *AuthConfig) Verify() error {
+	count := 0
+	if a.BasicAuth != nil {
+		count++
+		if err := a.BasicAuth.Verify(); err != nil {
+			return err
+		}
+	}
+	if a.BearerToken != nil {
+		count++
+		if err := a.BearerToken.Verify(); err != nil {
+			return err
+		}
+	}
+	if a.OAuth2 != nil {
+		count++
+		if err := a.OAuth2.Verify(); err != nil {
+			return err
+		}
+	}
+	if count > 1 {
+		return fmt.Errorf("at most one of basic_auth, bearer_token and oauth2 must be configured")
+	}
+	return nil
+}
+
+type BasicAuth struct {
+	Username     string        `json:"username" yaml:"username"`
+	Password     config.Secret `json:"password,omitempty" yaml:"password,omitempty"`
+	PasswordFile string        `json:"password_file,omitempty" yaml:"password_file,omitempty"`
+}
+
+func (b *BasicAuth) Verify() error {
+	if len(b.Username) == 0 {
+		return fmt.Errorf("basic_auth.username cannot be empty")
+	}
+	if len(b.Password) > 0 && len(b.PasswordFile) > 0 {
+		return fmt.Errorf("basic_auth.password and basic_auth.password_file are mutually exclusive")
+	}
+	return nil
+}
+
+type BearerToken struct {
+	Token     config.Secret `json:"token,omitempty" yaml:"token,omitempty"`
+	TokenFile string        `json:"token_file,omitempty" yaml:"token_file,omitempty"`
+}
+
+func (b *BearerToken) Verify() error {
+	if len(b.Token) > 0 && len(b.TokenFile) > 0 {
+		return fmt.Errorf("bearer_token.token and bearer_token.token_file are mutually exclusive")
+	}
+	if len(b.Token) == 0 && len(b.TokenFile) == 0 {
+		return fmt.Errorf("bearer_token.token or bearer_token.token_file must be set")
+	}
+	return nil
+}
+
+// OAuth2 configures the OAuth2 client credentials flow.
+type OAuth2 struct {
+	ClientID         string            `json:"client_id" yaml:"client_id"`
+	ClientSecret     config.Secret     `json:"client_secret,omitempty" yaml:"client_secret,omitempty"`
+	ClientSecretFile string            `json:"client_secret_file,omitempty" yaml:"client_secret_file,omitempty"`
+	TokenURL         string            `json:"token_url" yaml:"token_url"`
+	Scopes           []string          `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	EndpointParams   map[string]string `json:"endpoint_params,omitempty" yaml:"endpoint_params,omitempty"`
+}
+
+func (o *OAuth2) Verify() error {
+	if len(o.ClientID) == 0 {
+		return fmt.Errorf("oauth2.client_id cannot be empty")
+	}
+	if len(o.TokenURL) == 0 {
+		return fmt.Errorf("oauth2.token_url cannot be empty")
+	}
+	if _, err := url.Parse(o.TokenURL); err != nil {
+		return fmt.Errorf("oauth2.token_url is invalid: %w", err)
+	}
+	if len(o.ClientSecret) > 0 && len(o.ClientSecretFile) > 0 {
+		return fmt.Errorf("oauth2.client_secret and oauth2.client_secret_file are mutually exclusive")
+	}
+	return nil
+}
+
+// NewAuthRoundTripper returns a RoundTripper authenticating the requests according to the config before sending them with next.
+// When no method is configured, next is returned.
+func NewAuthRoundTripper(cfg AuthConfig, next http.RoundTripper) (http.RoundTripper, error) {
+	if err := cfg.Verify(); err != nil {
+		return nil, err
+	}
+	switch {
+	case cfg.BasicAuth != nil:
+		return NewBasicAuthRoundTripper(*cfg.BasicAuth, next), nil
+	case cfg.BearerToken != nil:
+		return NewBearerTokenRoundTripper(*cfg.BearerToken, next), nil
+	case cfg.OAuth2 != nil:
+		return NewOAuth2RoundTripper(*cfg.OAuth2, next), nil
+	default:
+		return next, nil
+	}
+}
+
+// NewBasicAuthRoundTripper returns a RoundTripper setting the basic authentication on every request.
+func NewBasicAuthRoundTripper(auth BasicAuth, next http.RoundTripper) http.RoundTripper {
+	return config.NewBasicAuthRoundTripper(config.NewInlineSecret(auth.Username), newSecretReader(auth.Password, auth.PasswordFile), next)
+}
+
+// NewBearerTokenRoundTripper returns a RoundTripper setting the header Authorization with the bearer token on every request.
+func NewBearerTokenRoundTripper(token BearerToken, next http.RoundTripper) http.RoundTripper {
+	return config.NewAuthorizationCredentialsRoundTripper("Bearer", newSecretReader(token.Token, token.TokenFile), next)
+}
+
+// NewOAuth2RoundTripper returns a RoundTripper getting a token with the OAuth2 client credentials flow and setting it on every request.
+// The token is cached until it expires. The token endpoint is called with next as well.
+func NewOAuth2RoundTripper(cfg OAuth2, next http.RoundTripper) http.RoundTripper {
+	return &oauth2RoundTripper{
+		cfg:    cfg,
+		secret: newSecretReader(cfg.ClientSecret, cfg.ClientSecretFile),
+		next:   next,
+	}
+}
+
+type oauth2RoundTripper struct {
+	cfg    OAuth2
+	secret config.SecretReader
+	next   http.RoundTripper
+	mutex  sync.Mutex
+	// lastSecret is the client secret used to create rt. When the secret changes, rt is created again to get a new token.
+	lastSecret string
+	rt         http.RoundTripper
+}
+
+func (o *oauth2RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	secret, err := o.secret.Fetch(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the oauth2 client secret: %w", err)
+	}
+	o.mutex.Lock()
+	if o.rt == nil || secret != o.lastSecret {
+		params := url.Values{}
+		for k, v := range o.cfg.EndpointParams {
+			params.Set(k, v)
+		}
+		credentials := &clientcredentials.Config{
+			ClientID:       o.cfg.ClientID,
+			ClientSecret:   secret,
+			TokenURL:       o.cfg.TokenURL,
+			Scopes:         o.cfg.Scopes,
+			EndpointParams: params,
+		}
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: o.next})
+		o.rt = &oauth2.Transport{Source: credentials.TokenSource(ctx), Base: o.next}
+		o.lastSecret = secret
+	}
+	rt := o.rt
+	o.mutex.Unlock()
+	return rt.RoundTrip(req)
+}
+
+// newSecretReader returns the reader of the secret provided inline or in a file.
+// The file is read on every request, so the secret can be rotated without restarting the application.
+func newSecretReader(inline config.Secret, filename string) config.SecretReader {
+	if len(filename) > 0 {
+		return config.NewFileSecret(filename)
+	}
+	return config.NewInlineSecret(string(inline))
+}
+
+// hasAuth returns true if the HTTPClientConfig configures the authentication of the requests.
+func hasAuth(cfg config.HTTPClientConfig) bool {
+	return cfg.BasicAuth != nil || cfg.Authorization != nil || cfg.OAuth2 != nil || len(cfg.BearerToken) > 0 || len(cfg.BearerTokenFile) > 0
+}
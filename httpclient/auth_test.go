@@ -0,0 +1,125 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newAuthServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			// the client secret is sent either in the basic auth or in the form depending on the server
+			_, secret, ok := r.BasicAuth()
+			if !ok {
+				_ = r.ParseForm()
+				secret = r.Form.Get("client_secret")
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token": "token-" + secret,
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+			})
+			return
+		}
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getAuthorization(t *testing.T, rt http.RoundTripper, url string) string {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	buf := make([]byte, 256)
+	n, _ := resp.Body.Read(buf)
+	return string(buf[:n])
+}
+
+func TestBearerTokenRoundTripper_Rotation(t *testing.T) {
+	server := newAuthServer(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	rt := NewBearerTokenRoundTripper(BearerToken{TokenFile: tokenFile}, http.DefaultTransport)
+	assert.Equal(t, "Bearer first", getAuthorization(t, rt, server.URL))
+
+	if err := os.WriteFile(tokenFile, []byte("second"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// make sure the modification time changes even on file systems with a low precision
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(tokenFile, future, future); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Bearer second", getAuthorization(t, rt, server.URL))
+}
+
+func TestNewAuthRoundTripper(t *testing.T) {
+	server := newAuthServer(t)
+	testSuites := []struct {
+		title  string
+		cfg    AuthConfig
+		result string
+	}{
+		{
+			title:  "basic auth",
+			cfg:    AuthConfig{BasicAuth: &BasicAuth{Username: "user", Password: "pwd"}},
+			result: "Basic dXNlcjpwd2Q=",
+		},
+		{
+			title:  "bearer token",
+			cfg:    AuthConfig{BearerToken: &BearerToken{Token: "token"}},
+			result: "Bearer token",
+		},
+		{
+			title:  "oauth2",
+			cfg:    AuthConfig{OAuth2: &OAuth2{ClientID: "id", ClientSecret: "secret", TokenURL: server.URL + "/token"}},
+			result: "Bearer token-secret",
+		},
+	}
+	for _, test := range testSuites {
+		t.Run(test.title, func(t *testing.T) {
+			rt, err := NewAuthRoundTripper(test.cfg, http.DefaultTransport)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, test.result, getAuthorization(t, rt, server.URL))
+		})
+	}
+}
+
+func TestAuthConfig_Verify(t *testing.T) {
+	cfg := AuthConfig{
+		BasicAuth:   &BasicAuth{Username: "user"},
+		BearerToken: &BearerToken{Token: "token"},
+	}
+	assert.Error(t, cfg.Verify())
+	cfg = AuthConfig{BearerToken: &BearerToken{}}
+	assert.Error(t, cfg.Verify())
+}
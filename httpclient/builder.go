@@ -0,0 +1,217 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package httpclient is exposing a Builder to create the HTTP clients used to call other services.
+// It is the counterpart of the echo package for the outbound traffic: the client is instrumented with Prometheus metrics,
+// traced with OpenTelemetry and relies on logrus for logging (see https://github.com/sirupsen/logrus).
+//
+// # Features
+//
+// - Configure the client (TLS, authentication, proxy, ...) with the HTTPClientConfig from github.com/prometheus/common/config.
+//
+// - Resolve the relative URLs against a base URL.
+//
+// - Authenticate the requests with a basic auth, a bearer token or the OAuth2 client credentials flow.
+//
+// - Retry the failing requests with an exponential backoff.
+//
+// - Apply a timeout on every attempt.
+//
+// - Expose the metrics http_client_requests_total and http_client_request_duration_seconds by host and status code.
+//
+// - Trace the requests and propagate the trace context to the server.
+//
+// # Usage
+//
+//	client, err := httpclient.NewBuilder("my_service").
+//	        BaseURL("https://my-service.example.com/api/").
+//	        HTTPClientConfig(cfg.HTTPClient).
+//	        RetryPolicy(httpclient.DefaultRetryPolicy).
+//	        Timeout(10 * time.Second).
+//	        ActivateTracing(true).
+//	        Build()
+//	resp, err := client.Get("v1/projects")
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/config"
+)
+
+// RequestHook is called before every attempt to send the request.
+type RequestHook func(req *http.Request)
+
+// ResponseHook is called after every attempt to send the request. Either resp or err is nil.
+// The hook must not read or close the body of the response.
+type ResponseHook func(req *http.Request, resp *http.Response, err error, duration time.Duration)
+
+type Builder struct {
+	name            string
+	baseURL         *url.URL
+	clientConfig    config.HTTPClientConfig
+	transport       http.RoundTripper
+	auth            *AuthConfig
+	retryPolicy     *RetryPolicy
+	timeout         time.Duration
+	promRegisterer  prometheus.Registerer
+	activateTracing bool
+	requestHooks    []RequestHook
+	responseHooks   []ResponseHook
+	err             error
+}
+
+// NewBuilder returns a Builder for a client named name. The name is used in the metrics, the traces and the logs to identify the client.
+func NewBuilder(name string) *Builder {
+	return &Builder{
+		name:           name,
+		clientConfig:   config.DefaultHTTPClientConfig,
+		promRegisterer: prometheus.DefaultRegisterer,
+	}
+}
+
+// BaseURL sets the URL used to resolve the relative URLs of the requests.
+// A trailing slash is usually needed for the path of the request to be appended to the one of the base URL.
+func (b *Builder) BaseURL(rawURL string) *Builder {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		b.err = fmt.Errorf("invalid base URL: %w", err)
+		return b
+	}
+	b.baseURL = u
+	return b
+}
+
+// HTTPClientConfig sets the configuration (TLS, authentication, proxy, ...) used to create the underlying transport.
+func (b *Builder) HTTPClientConfig(cfg config.HTTPClientConfig) *Builder {
+	b.clientConfig = cfg
+	return b
+}
+
+// Transport sets the RoundTripper that sends the requests.
+// When set, the HTTPClientConfig is ignored. It is mainly useful for testing purpose.
+func (b *Builder) Transport(rt http.RoundTripper) *Builder {
+	b.transport = rt
+	return b
+}
+
+// Auth sets how the client authenticates itself (see AuthConfig).
+// It cannot be combined with the authentication of the HTTPClientConfig (basic_auth, authorization, bearer_token or oauth2).
+func (b *Builder) Auth(cfg AuthConfig) *Builder {
+	b.auth = &cfg
+	return b
+}
+
+// RetryPolicy activates the retry of the failing requests. By default, the requests are not retried.
+func (b *Builder) RetryPolicy(policy RetryPolicy) *Builder {
+	b.retryPolicy = &policy
+	return b
+}
+
+// Timeout sets the maximum duration of every attempt to send the request, including the reading of the response body.
+// Zero means no timeout.
+func (b *Builder) Timeout(timeout time.Duration) *Builder {
+	b.timeout = timeout
+	return b
+}
+
+// PrometheusRegisterer will set a new metric registry for Prometheus, so it won't use the default one.
+// Set it to nil to deactivate the metrics.
+func (b *Builder) PrometheusRegisterer(r prometheus.Registerer) *Builder {
+	b.promRegisterer = r
+	return b
+}
+
+// ActivateTracing creates a span for every attempt to send the request and propagates the trace context in the headers of the request.
+// The spans are created with the global TracerProvider (see the otel package).
+func (b *Builder) ActivateTracing(activate bool) *Builder {
+	b.activateTracing = activate
+	return b
+}
+
+// OnRequest adds a hook called before every attempt to send the request. It can be used to log the request.
+func (b *Builder) OnRequest(hook RequestHook) *Builder {
+	b.requestHooks = append(b.requestHooks, hook)
+	return b
+}
+
+// OnResponse adds a hook called after every attempt to send the request. It can be used to log the response.
+func (b *Builder) OnResponse(hook ResponseHook) *Builder {
+	b.responseHooks = append(b.responseHooks, hook)
+	return b
+}
+
+// BuildRoundTripper returns the RoundTripper wrapping the transport with the features configured.
+func (b *Builder) BuildRoundTripper() (http.RoundTripper, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.name) == 0 {
+		return nil, fmt.Errorf("the name of the client cannot be empty")
+	}
+	if b.auth != nil && hasAuth(b.clientConfig) {
+		return nil, fmt.Errorf("the authentication is configured both in the HTTPClientConfig and with the method Auth")
+	}
+	rt := b.transport
+	if rt == nil {
+		if err := b.clientConfig.Validate(); err != nil {
+			return nil, err
+		}
+		var err error
+		rt, err = config.NewRoundTripperFromConfig(b.clientConfig, b.name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if b.auth != nil {
+		var err error
+		rt, err = NewAuthRoundTripper(*b.auth, rt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The round trippers are wrapped from the inner one to the outer one.
+	// Every attempt done by the retry is measured, traced and logged.
+	if len(b.requestHooks) > 0 || len(b.responseHooks) > 0 {
+		rt = &hookRoundTripper{next: rt, requestHooks: b.requestHooks, responseHooks: b.responseHooks}
+	}
+	if b.promRegisterer != nil {
+		rt = newMetricsRoundTripper(rt, b.name, b.promRegisterer)
+	}
+	if b.activateTracing {
+		rt = newTracingRoundTripper(rt, b.name)
+	}
+	if b.timeout > 0 {
+		rt = &timeoutRoundTripper{next: rt, timeout: b.timeout}
+	}
+	if b.retryPolicy != nil {
+		rt = &retryRoundTripper{next: rt, policy: b.retryPolicy.withDefaults()}
+	}
+	if b.baseURL != nil {
+		rt = &baseURLRoundTripper{next: rt, baseURL: b.baseURL}
+	}
+	return rt, nil
+}
+
+// Build returns an HTTP client using the RoundTripper returned by BuildRoundTripper.
+func (b *Builder) Build() (*http.Client, error) {
+	rt, err := b.BuildRoundTripper()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: rt}, nil
+}
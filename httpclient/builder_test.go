@@ -0,0 +1,128 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder_BaseURLAndMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+	registry := prometheus.NewRegistry()
+	client, err := NewBuilder("test").
+		BaseURL(server.URL + "/api/").
+		PrometheusRegisterer(registry).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get("v1/projects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, "/api/v1/projects", string(body))
+	assert.Equal(t, 1, testutil.CollectAndCount(registry, "http_client_requests_total"))
+}
+
+func TestBuilder_Retry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+	client, err := NewBuilder("test").
+		PrometheusRegisterer(nil).
+		RetryPolicy(RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodPut, server.URL, strings.NewReader("hello"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", string(body))
+	assert.Equal(t, int32(3), calls.Load())
+
+	// POST is not idempotent and so not retried
+	calls.Store(0)
+	resp, err = client.Post(server.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestBuilder_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+	client, err := NewBuilder("test").
+		PrometheusRegisterer(nil).
+		Timeout(50 * time.Millisecond).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Get(server.URL)
+	assert.ErrorContains(t, err, "deadline exceeded")
+}
+
+func TestBuilder_AuthConflict(t *testing.T) {
+	clientConfig := config.DefaultHTTPClientConfig
+	clientConfig.BasicAuth = &config.BasicAuth{Username: "user", Password: "password"}
+	_, err := NewBuilder("test").
+		PrometheusRegisterer(nil).
+		HTTPClientConfig(clientConfig).
+		Auth(AuthConfig{BearerToken: &BearerToken{Token: "token"}}).
+		Build()
+	assert.EqualError(t, err, "the authentication is configured both in the HTTPClientConfig and with the method Auth")
+
+	_, err = NewBuilder("test").
+		PrometheusRegisterer(nil).
+		Auth(AuthConfig{BearerToken: &BearerToken{Token: "token"}}).
+		Build()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,67 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/perses/common/promhelper"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	labelClient = "client"
+	labelCode   = "code"
+	labelHost   = "host"
+	labelMethod = "method"
+	// codeError is the value of the label code when no response has been received.
+	codeError = "error"
+)
+
+type metricsRoundTripper struct {
+	next     http.RoundTripper
+	name     string
+	total    *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func newMetricsRoundTripper(next http.RoundTripper, name string, r prometheus.Registerer) *metricsRoundTripper {
+	return &metricsRoundTripper{
+		next: next,
+		name: name,
+		total: promhelper.RegisterOrReuseOrLog(r, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_client_requests_total",
+			Help: "Total of HTTP requests sent by the client",
+		}, []string{labelClient, labelHost, labelMethod, labelCode})),
+		duration: promhelper.RegisterOrReuseOrLog(r, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_client_request_duration_seconds",
+			Help:    "Latencies in second of the HTTP requests sent by the client",
+			Buckets: prometheus.DefBuckets,
+		}, []string{labelClient, labelHost, labelMethod})),
+	}
+}
+
+func (m *metricsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := m.next.RoundTrip(req)
+	code := codeError
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	m.total.WithLabelValues(m.name, req.URL.Host, req.Method, code).Inc()
+	m.duration.WithLabelValues(m.name, req.URL.Host, req.Method).Observe(time.Since(start).Seconds())
+	return resp, err
+}
@@ -0,0 +1,146 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RetryPolicy describes when and how often a request is retried.
+// Only the requests with an idempotent method or a body that can be read again (see http.Request.GetBody) are retried.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries. The request is sent at most MaxRetries+1 times.
+	MaxRetries int
+	// InitialBackoff is the duration to wait before the first retry. It is doubled after every retry.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum duration to wait between two attempts.
+	MaxBackoff time.Duration
+	// ShouldRetry tells if the attempt must be retried. When nil, the network errors and the status codes 429, 502, 503 and 504 are retried.
+	ShouldRetry func(resp *http.Response, err error) bool
+}
+
+// DefaultRetryPolicy retries 3 times the failing requests, waiting from 100ms to 2s between the attempts.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:     3,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = DefaultRetryPolicy.InitialBackoff
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		p.MaxBackoff = p.InitialBackoff
+	}
+	if p.ShouldRetry == nil {
+		p.ShouldRetry = defaultShouldRetry
+	}
+	return p
+}
+
+func defaultShouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+type retryRoundTripper struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+}
+
+func (r *retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isRetryable(req) {
+		return r.next.RoundTrip(req)
+	}
+	backoff := r.policy.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+		resp, err := r.next.RoundTrip(attemptReq)
+		if attempt >= r.policy.MaxRetries || !r.policy.ShouldRetry(resp, err) {
+			return resp, err
+		}
+		wait := retryAfter(resp, backoff, r.policy.MaxBackoff)
+		if resp != nil {
+			// the body must be consumed and closed to reuse the connection
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		logrus.WithError(err).Debugf("request %s %s failed, retrying in %s", req.Method, req.URL.Redacted(), wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, errors.Join(req.Context().Err(), err)
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, r.policy.MaxBackoff)
+	}
+}
+
+func isRetryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// the body cannot be sent twice
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		// Non-idempotent requests are only retried when the caller explicitly marks them as such,
+		// like the http package does with the header Idempotency-Key.
+		return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+	}
+}
+
+// retryAfter returns the duration to wait before the next attempt, honoring the header Retry-After if it is present.
+func retryAfter(resp *http.Response, backoff time.Duration, maxBackoff time.Duration) time.Duration {
+	if resp == nil {
+		return backoff
+	}
+	value := resp.Header.Get("Retry-After")
+	if len(value) == 0 {
+		return backoff
+	}
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if date, dateErr := http.ParseTime(value); dateErr == nil {
+		wait = time.Until(date)
+	} else {
+		return backoff
+	}
+	return max(min(wait, maxBackoff), 0)
+}
@@ -0,0 +1,85 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type baseURLRoundTripper struct {
+	next    http.RoundTripper
+	baseURL *url.URL
+}
+
+func (b *baseURLRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.IsAbs() {
+		return b.next.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the request
+	newReq := req.Clone(req.Context())
+	newReq.URL = b.baseURL.ResolveReference(req.URL)
+	newReq.Host = ""
+	return b.next.RoundTrip(newReq)
+}
+
+type timeoutRoundTripper struct {
+	next    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *timeoutRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the context must live until the body is read
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelBody) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+type hookRoundTripper struct {
+	next          http.RoundTripper
+	requestHooks  []RequestHook
+	responseHooks []ResponseHook
+}
+
+func (h *hookRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	for _, hook := range h.requestHooks {
+		hook(req)
+	}
+	start := time.Now()
+	resp, err := h.next.RoundTrip(req)
+	duration := time.Since(start)
+	for _, hook := range h.responseHooks {
+		hook(req, resp, err, duration)
+	}
+	return resp, err
+}
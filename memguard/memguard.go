@@ -0,0 +1,170 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package memguard provides a task watching the heap of the application to warn before it is killed for lack of memory.
+// It can also set the soft memory limit and the GC target percentage (the equivalent of GOMEMLIMIT and GOGC) from the configuration.
+//
+// # Usage
+//
+//	task, err := memguard.NewTask(config.MemGuard, prometheus.DefaultRegisterer)
+//	app.NewRunner().WithTimerTasks(10*time.Second, task)
+package memguard
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"runtime/metrics"
+
+	"github.com/perses/common/async"
+	"github.com/perses/common/promhelper"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	heapMetric = "/memory/classes/heap/objects:bytes"
+
+	thresholdWarning  = "warning"
+	thresholdCritical = "critical"
+)
+
+type Config struct {
+	// MemoryLimit is the soft memory limit in bytes (see debug.SetMemoryLimit). When 0, the limit is left untouched.
+	MemoryLimit int64 `json:"memory_limit,omitempty" yaml:"memory_limit,omitempty"`
+	// GCPercent is the GC target percentage (see debug.SetGCPercent). When not set, it is left untouched.
+	GCPercent *int `json:"gc_percent,omitempty" yaml:"gc_percent,omitempty"`
+	// WarningThreshold is the heap size in bytes above which a warning is logged. When 0, there is no warning.
+	WarningThreshold int64 `json:"warning_threshold,omitempty" yaml:"warning_threshold,omitempty"`
+	// CriticalThreshold is the heap size in bytes above which an error is logged. When 0, there is no error.
+	CriticalThreshold int64 `json:"critical_threshold,omitempty" yaml:"critical_threshold,omitempty"`
+	// RestartOnCritical stops gracefully the application when the critical threshold is crossed,
+	// so it can be restarted by its supervisor (systemd, Kubernetes...) before being killed.
+	RestartOnCritical bool `json:"restart_on_critical,omitempty" yaml:"restart_on_critical,omitempty"`
+}
+
+func (c *Config) Verify() error {
+	if c.MemoryLimit < 0 || c.WarningThreshold < 0 || c.CriticalThreshold < 0 {
+		return fmt.Errorf("memory_limit, warning_threshold and critical_threshold cannot be negative")
+	}
+	if c.WarningThreshold > 0 && c.CriticalThreshold > 0 && c.WarningThreshold > c.CriticalThreshold {
+		return fmt.Errorf("warning_threshold cannot be greater than critical_threshold")
+	}
+	if c.RestartOnCritical && c.CriticalThreshold == 0 {
+		return fmt.Errorf("critical_threshold must be set when restart_on_critical is enabled")
+	}
+	return nil
+}
+
+type task struct {
+	async.Task
+	cfg       Config
+	heap      prometheus.Gauge
+	crossings *prometheus.CounterVec
+	// level is the last threshold crossed. It is empty when the heap is below every threshold.
+	level  string
+	sample []metrics.Sample
+}
+
+// NewTask returns a task checking the heap every time it is executed. It is meant to be run periodically (see app.Runner.WithTimerTasks).
+// The memory limit and the GC target percentage are applied when the task is initialized.
+// When r is nil, no metric is exposed.
+func NewTask(cfg Config, r prometheus.Registerer) (async.Task, error) {
+	if err := cfg.Verify(); err != nil {
+		return nil, err
+	}
+	t := &task{
+		cfg:    cfg,
+		sample: []metrics.Sample{{Name: heapMetric}},
+		heap: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "memory_guard_heap_bytes",
+			Help: "Size of the heap as observed by the memory guard",
+		}),
+		crossings: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "memory_guard_threshold_crossings_total",
+			Help: "Number of times the heap went above the threshold",
+		}, []string{"threshold"}),
+	}
+	if r != nil {
+		var err error
+		if t.heap, err = promhelper.RegisterOrReuse(r, t.heap); err != nil {
+			return nil, err
+		}
+		if t.crossings, err = promhelper.RegisterOrReuse(r, t.crossings); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (t *task) String() string {
+	return "memory guard"
+}
+
+func (t *task) Initialize() error {
+	if t.cfg.MemoryLimit > 0 {
+		previous := debug.SetMemoryLimit(t.cfg.MemoryLimit)
+		async.Logger(context.Background()).Infof("memory limit set to %d bytes (previously %d bytes)", t.cfg.MemoryLimit, previous)
+	}
+	if t.cfg.GCPercent != nil {
+		previous := debug.SetGCPercent(*t.cfg.GCPercent)
+		async.Logger(context.Background()).Infof("GC target percentage set to %d (previously %d)", *t.cfg.GCPercent, previous)
+	}
+	return nil
+}
+
+func (t *task) Execute(ctx context.Context, cancelFunc context.CancelFunc) error {
+	metrics.Read(t.sample)
+	heap := int64(t.sample[0].Value.Uint64())
+	t.heap.Set(float64(heap))
+	level := t.levelOf(heap)
+	if level == t.level {
+		// only the transitions are reported, otherwise the logs would be flooded as long as the heap stays high
+		return nil
+	}
+	previous := t.level
+	t.level = level
+	logger := async.Logger(ctx).WithField("heap_bytes", heap)
+	switch level {
+	case thresholdWarning:
+		if previous == thresholdCritical {
+			logger.Info("heap went back below the critical threshold")
+			return nil
+		}
+		t.crossings.WithLabelValues(thresholdWarning).Inc()
+		logger.Warnf("heap is above the warning threshold (%d bytes)", t.cfg.WarningThreshold)
+	case thresholdCritical:
+		t.crossings.WithLabelValues(thresholdCritical).Inc()
+		logger.Errorf("heap is above the critical threshold (%d bytes)", t.cfg.CriticalThreshold)
+		if t.cfg.RestartOnCritical {
+			logger.Error("stopping the application to let it be restarted")
+			cancelFunc()
+		}
+	default:
+		logger.Info("heap went back below the thresholds")
+	}
+	return nil
+}
+
+func (t *task) Finalize() error {
+	return nil
+}
+
+func (t *task) levelOf(heap int64) string {
+	if t.cfg.CriticalThreshold > 0 && heap >= t.cfg.CriticalThreshold {
+		return thresholdCritical
+	}
+	if t.cfg.WarningThreshold > 0 && heap >= t.cfg.WarningThreshold {
+		return thresholdWarning
+	}
+	return ""
+}
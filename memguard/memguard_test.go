@@ -0,0 +1,88 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memguard
+
+import (
+	"context"
+	"runtime/debug"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_Verify(t *testing.T) {
+	testSuites := []struct {
+		title   string
+		cfg     Config
+		isValid bool
+	}{
+		{title: "empty config", cfg: Config{}, isValid: true},
+		{title: "valid thresholds", cfg: Config{WarningThreshold: 10, CriticalThreshold: 20, RestartOnCritical: true}, isValid: true},
+		{title: "negative limit", cfg: Config{MemoryLimit: -1}},
+		{title: "warning above critical", cfg: Config{WarningThreshold: 20, CriticalThreshold: 10}},
+		{title: "restart without critical threshold", cfg: Config{WarningThreshold: 20, RestartOnCritical: true}},
+	}
+	for _, test := range testSuites {
+		t.Run(test.title, func(t *testing.T) {
+			err := test.cfg.Verify()
+			if test.isValid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestTask_Initialize(t *testing.T) {
+	gcPercent := 150
+	task, err := NewTask(Config{MemoryLimit: 1 << 40, GCPercent: &gcPercent}, nil)
+	require.NoError(t, err)
+	previousLimit := debug.SetMemoryLimit(-1)
+	previousGC := debug.SetGCPercent(-1)
+	defer func() {
+		debug.SetMemoryLimit(previousLimit)
+		debug.SetGCPercent(previousGC)
+	}()
+	require.NoError(t, task.Initialize())
+	assert.Equal(t, int64(1<<40), debug.SetMemoryLimit(-1))
+	assert.Equal(t, gcPercent, debug.SetGCPercent(-1))
+}
+
+func TestTask_Execute(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	// any running program has a heap bigger than 1 byte
+	guard, err := NewTask(Config{CriticalThreshold: 1, RestartOnCritical: true}, registry)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, guard.Execute(ctx, cancel))
+	require.NoError(t, guard.Execute(ctx, cancel))
+	assert.Error(t, ctx.Err())
+	crossings := guard.(*task).crossings
+	assert.Equal(t, float64(1), testutil.ToFloat64(crossings.WithLabelValues(thresholdCritical)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(crossings.WithLabelValues(thresholdWarning)))
+}
+
+func TestNewTask_SameRegisterer(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	first, err := NewTask(Config{}, registry)
+	require.NoError(t, err)
+	second, err := NewTask(Config{}, registry)
+	require.NoError(t, err)
+	assert.Same(t, first.(*task).crossings, second.(*task).crossings)
+}
@@ -0,0 +1,219 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package profiling provides a task capturing periodically the pprof profiles of the application,
+// so profiling data is available for a post-mortem analysis without manual intervention.
+// The profiles are written in a directory (keeping only the most recent ones) and/or pushed to an HTTP endpoint.
+//
+// # Usage
+//
+//	task, err := profiling.NewTask(profiling.Options{
+//	    Dir:       "/var/lib/my_app/profiles",
+//	    Retention: 24,
+//	})
+//	app.NewRunner().WithTimerTasks(time.Hour, task)
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/perses/common/async"
+	"github.com/perses/common/file"
+)
+
+const (
+	ProfileCPU       = "cpu"
+	ProfileHeap      = "heap"
+	ProfileAllocs    = "allocs"
+	ProfileGoroutine = "goroutine"
+	ProfileBlock     = "block"
+	ProfileMutex     = "mutex"
+
+	fileExtension = ".pb.gz"
+	// timestampFormat is sortable, so the oldest files are the first ones in the lexical order.
+	timestampFormat = "20060102T150405Z"
+)
+
+type Options struct {
+	// Dir is the directory where the profiles are written. When empty, the profiles are not written on disk.
+	Dir string
+	// Retention is the number of files kept in Dir for each kind of profile. By default, 10 files are kept.
+	Retention int
+	// Profiles is the list of profiles captured. By default, the CPU and the heap are captured.
+	Profiles []string
+	// CPUDuration is the duration of the CPU profiling. By default, it is 10 seconds.
+	CPUDuration time.Duration
+	// PushURL is the endpoint where every profile is sent with a POST request. The kind of profile is given with the query parameter "profile".
+	// When empty, the profiles are not pushed.
+	PushURL string
+	// HTTPClient is used to push the profiles. By default, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+type task struct {
+	async.SimpleTask
+	opts Options
+}
+
+// NewTask returns a task capturing the profiles every time it is executed. It is meant to be run periodically (see app.Runner.WithTimerTasks).
+func NewTask(opts Options) (async.SimpleTask, error) {
+	if len(opts.Dir) == 0 && len(opts.PushURL) == 0 {
+		return nil, fmt.Errorf("a directory or a push URL must be set to store the profiles")
+	}
+	if len(opts.PushURL) > 0 {
+		if _, err := url.Parse(opts.PushURL); err != nil {
+			return nil, fmt.Errorf("invalid push URL: %w", err)
+		}
+	}
+	if len(opts.Dir) > 0 {
+		if err := os.MkdirAll(opts.Dir, 0750); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = 10
+	}
+	if len(opts.Profiles) == 0 {
+		opts.Profiles = []string{ProfileCPU, ProfileHeap}
+	}
+	for _, p := range opts.Profiles {
+		if p != ProfileCPU && pprof.Lookup(p) == nil {
+			return nil, fmt.Errorf("unknown profile %q", p)
+		}
+	}
+	if opts.CPUDuration <= 0 {
+		opts.CPUDuration = 10 * time.Second
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	return &task{opts: opts}, nil
+}
+
+func (t *task) String() string {
+	return "profiling"
+}
+
+func (t *task) Execute(ctx context.Context, _ context.CancelFunc) error {
+	logger := async.Logger(ctx)
+	for _, profile := range t.opts.Profiles {
+		data, err := t.capture(ctx, profile)
+		if err != nil {
+			logger.WithError(err).Errorf("unable to capture the %s profile", profile)
+			continue
+		}
+		if ctx.Err() != nil {
+			// the capture has been interrupted, the profile is incomplete
+			return nil
+		}
+		if err := t.store(profile, data); err != nil {
+			logger.WithError(err).Errorf("unable to store the %s profile", profile)
+		}
+		if err := t.push(ctx, profile, data); err != nil {
+			logger.WithError(err).Errorf("unable to push the %s profile", profile)
+		}
+	}
+	return nil
+}
+
+func (t *task) capture(ctx context.Context, profile string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if profile != ProfileCPU {
+		if err := pprof.Lookup(profile).WriteTo(buf, 0); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	// it fails if the CPU is already profiled (through the endpoint /debug/pprof/profile for example)
+	if err := pprof.StartCPUProfile(buf); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(t.opts.CPUDuration)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+	case <-timer.C:
+	}
+	pprof.StopCPUProfile()
+	return buf.Bytes(), nil
+}
+
+func (t *task) store(profile string, data []byte) error {
+	if len(t.opts.Dir) == 0 {
+		return nil
+	}
+	filename := filepath.Join(t.opts.Dir, fmt.Sprintf("%s-%s%s", profile, time.Now().UTC().Format(timestampFormat), fileExtension))
+	if err := file.WriteAtomic(filename, data, 0640); err != nil {
+		return err
+	}
+	return t.applyRetention(profile)
+}
+
+// applyRetention removes the oldest files of the profile to keep only opts.Retention files.
+func (t *task) applyRetention(profile string) error {
+	entries, err := os.ReadDir(t.opts.Dir)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, profile+"-") && strings.HasSuffix(name, fileExtension) {
+			files = append(files, name)
+		}
+	}
+	if len(files) <= t.opts.Retention {
+		return nil
+	}
+	sort.Strings(files)
+	for _, name := range files[:len(files)-t.opts.Retention] {
+		if err := os.Remove(filepath.Join(t.opts.Dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *task) push(ctx context.Context, profile string, data []byte) error {
+	if len(t.opts.PushURL) == 0 {
+		return nil
+	}
+	u, _ := url.Parse(t.opts.PushURL)
+	query := u.Query()
+	query.Set("profile", profile)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := t.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiling
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTask(t *testing.T) {
+	_, err := NewTask(Options{})
+	assert.Error(t, err)
+	_, err = NewTask(Options{Dir: t.TempDir(), Profiles: []string{"unknown"}})
+	assert.Error(t, err)
+}
+
+func TestTask_Retention(t *testing.T) {
+	dir := t.TempDir()
+	task, err := NewTask(Options{Dir: dir, Retention: 2, Profiles: []string{ProfileHeap, ProfileGoroutine}})
+	require.NoError(t, err)
+	// add old profiles to check they are removed
+	for i := 0; i < 3; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("heap-2000010%dT000000Z%s", i, fileExtension)), nil, 0600))
+	}
+	require.NoError(t, task.Execute(context.Background(), func() {}))
+
+	heaps, err := filepath.Glob(filepath.Join(dir, "heap-*"))
+	require.NoError(t, err)
+	require.Len(t, heaps, 2)
+	assert.Equal(t, "heap-20000102T000000Z"+fileExtension, filepath.Base(heaps[0]))
+	data, err := os.ReadFile(heaps[1])
+	require.NoError(t, err)
+	assert.NotEmpty(t, data)
+
+	goroutines, err := filepath.Glob(filepath.Join(dir, "goroutine-*"))
+	require.NoError(t, err)
+	assert.Len(t, goroutines, 1)
+}
+
+func TestTask_Push(t *testing.T) {
+	mutex := sync.Mutex{}
+	received := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		received[r.URL.Query().Get("profile")] = len(body)
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	task, err := NewTask(Options{PushURL: server.URL, CPUDuration: 100 * time.Millisecond})
+	require.NoError(t, err)
+	require.NoError(t, task.Execute(context.Background(), func() {}))
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Contains(t, received, ProfileCPU)
+	assert.Greater(t, received[ProfileHeap], 0)
+}
@@ -0,0 +1,51 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package promhelper contains the helpers shared by the packages exposing Prometheus metrics.
+package promhelper
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// RegisterOrReuse registers the collector. If an identical collector is already registered (e.g. when several instances of
+// the same component share the registerer), the existing one is returned instead, so the instances update the same metrics.
+func RegisterOrReuse[C prometheus.Collector](r prometheus.Registerer, c C) (C, error) {
+	err := r.Register(c)
+	if err == nil {
+		return c, nil
+	}
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if !errors.As(err, &alreadyRegistered) {
+		return c, err
+	}
+	existing, ok := alreadyRegistered.ExistingCollector.(C)
+	if !ok {
+		return c, fmt.Errorf("a collector of the type %T is already registered with the same description", alreadyRegistered.ExistingCollector)
+	}
+	return existing, nil
+}
+
+// RegisterOrReuseOrLog is like RegisterOrReuse, but the error is only logged. The collector is then returned, so it can still be updated
+// even though its metrics are not exposed.
+func RegisterOrReuseOrLog[C prometheus.Collector](r prometheus.Registerer, c C) C {
+	result, err := RegisterOrReuse(r, c)
+	if err != nil {
+		logrus.WithError(err).Error("unable to register the metrics")
+	}
+	return result
+}
@@ -0,0 +1,37 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package promhelper
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterOrReuse(t *testing.T) {
+	r := prometheus.NewRegistry()
+	opts := prometheus.CounterOpts{Name: "ut_total", Help: "help"}
+	first, err := RegisterOrReuse(r, prometheus.NewCounterVec(opts, []string{"label"}))
+	assert.NoError(t, err)
+	second, err := RegisterOrReuse(r, prometheus.NewCounterVec(opts, []string{"label"}))
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+
+	// same name but different labels
+	_, err = RegisterOrReuse(r, prometheus.NewCounterVec(opts, []string{"other"}))
+	assert.Error(t, err)
+	counter := RegisterOrReuseOrLog(r, prometheus.NewCounterVec(opts, []string{"other"}))
+	assert.NotNil(t, counter)
+}
@@ -0,0 +1,196 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package push provides a task pushing the metrics to a Prometheus Pushgateway.
+// It is useful for the short-lived programs (like batch jobs) built with the package app that are never scraped.
+//
+// # Usage
+//
+//	pushTask, err := push.NewBuilder("http://pushgateway:9091", "my_batch").
+//	        Gatherer(registry).
+//	        Metrics("my_batch_processed_total", "my_batch_duration_seconds").
+//	        Interval(30 * time.Second).
+//	        Build()
+//	app.NewRunner().WithTasks(pushTask, batchTask).Start()
+//
+// The metrics are pushed periodically and a last time when the task is finalized, so the final state of the job is always pushed.
+package push
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/perses/common/async"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultFinalPushTimeout = 5 * time.Second
+
+type Builder struct {
+	url      string
+	job      string
+	gatherer prometheus.Gatherer
+	metrics  map[string]struct{}
+	grouping map[string]string
+	client   push.HTTPDoer
+	interval time.Duration
+	add      bool
+}
+
+// NewBuilder returns a Builder for a task pushing the metrics to the Pushgateway reachable at url under the given job name.
+func NewBuilder(url string, job string) *Builder {
+	return &Builder{
+		url:      url,
+		job:      job,
+		gatherer: prometheus.DefaultGatherer,
+		grouping: make(map[string]string),
+	}
+}
+
+// Gatherer sets where the metrics are gathered. By default, prometheus.DefaultGatherer is used.
+func (b *Builder) Gatherer(g prometheus.Gatherer) *Builder {
+	b.gatherer = g
+	return b
+}
+
+// Metrics restricts the metrics pushed to the ones with the given names. By default, every metric gathered is pushed.
+func (b *Builder) Metrics(names ...string) *Builder {
+	if b.metrics == nil {
+		b.metrics = make(map[string]struct{}, len(names))
+	}
+	for _, name := range names {
+		b.metrics[name] = struct{}{}
+	}
+	return b
+}
+
+// Grouping adds a label used by the Pushgateway to group the metrics, in addition of the job name.
+func (b *Builder) Grouping(name string, value string) *Builder {
+	b.grouping[name] = value
+	return b
+}
+
+// HTTPClient sets the client used to call the Pushgateway (see the package httpclient to build one). By default, http.DefaultClient is used.
+func (b *Builder) HTTPClient(client push.HTTPDoer) *Builder {
+	b.client = client
+	return b
+}
+
+// Interval sets the frequency of the push. When it is zero (the default), the metrics are only pushed when the task is finalized.
+func (b *Builder) Interval(interval time.Duration) *Builder {
+	b.interval = interval
+	return b
+}
+
+// AddMode makes the task use the method POST instead of PUT. With POST, the Pushgateway only replaces the metrics with the same name
+// instead of replacing every metric of the group.
+func (b *Builder) AddMode(add bool) *Builder {
+	b.add = add
+	return b
+}
+
+func (b *Builder) Build() (async.Task, error) {
+	if len(b.url) == 0 {
+		return nil, fmt.Errorf("the URL of the pushgateway cannot be empty")
+	}
+	if len(b.job) == 0 {
+		return nil, fmt.Errorf("the job name cannot be empty")
+	}
+	if b.interval < 0 {
+		return nil, fmt.Errorf("the interval cannot be negative")
+	}
+	var gatherer = b.gatherer
+	if b.metrics != nil {
+		gatherer = &filteredGatherer{gatherer: gatherer, names: b.metrics}
+	}
+	pusher := push.New(b.url, b.job).Gatherer(gatherer)
+	for name, value := range b.grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	if b.client != nil {
+		pusher = pusher.Client(b.client)
+	}
+	if err := pusher.Error(); err != nil {
+		return nil, err
+	}
+	return &pushTask{pusher: pusher, interval: b.interval, add: b.add, job: b.job}, nil
+}
+
+type filteredGatherer struct {
+	gatherer prometheus.Gatherer
+	names    map[string]struct{}
+}
+
+func (f *filteredGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := f.gatherer.Gather()
+	result := make([]*dto.MetricFamily, 0, len(f.names))
+	for _, family := range families {
+		if _, ok := f.names[family.GetName()]; ok {
+			result = append(result, family)
+		}
+	}
+	return result, err
+}
+
+type pushTask struct {
+	async.Task
+	pusher   *push.Pusher
+	interval time.Duration
+	add      bool
+	job      string
+}
+
+func (p *pushTask) String() string {
+	return fmt.Sprintf("pushgateway pusher for the job %s", p.job)
+}
+
+func (p *pushTask) Initialize() error {
+	return nil
+}
+
+func (p *pushTask) Execute(ctx context.Context, _ context.CancelFunc) error {
+	if p.interval == 0 {
+		<-ctx.Done()
+		return nil
+	}
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := p.push(ctx); err != nil {
+				logrus.WithError(err).Error("unable to push the metrics to the pushgateway")
+			}
+		}
+	}
+}
+
+// Finalize pushes the metrics a last time, so the final state of the job is known by the Pushgateway.
+func (p *pushTask) Finalize() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultFinalPushTimeout)
+	defer cancel()
+	return p.push(ctx)
+}
+
+func (p *pushTask) push(ctx context.Context) error {
+	if p.add {
+		return p.pusher.AddContext(ctx)
+	}
+	return p.pusher.PushContext(ctx)
+}
@@ -0,0 +1,81 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package push
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushTask(t *testing.T) {
+	var mutex sync.Mutex
+	var bodies []string
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		bodies = append(bodies, string(body))
+		paths = append(paths, r.URL.Path)
+		mutex.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	processed := prometheus.NewCounter(prometheus.CounterOpts{Name: "batch_processed_total", Help: "processed"})
+	ignored := prometheus.NewCounter(prometheus.CounterOpts{Name: "batch_ignored_total", Help: "ignored"})
+	registry.MustRegister(processed, ignored)
+	processed.Inc()
+
+	task, err := NewBuilder(server.URL, "batch").
+		Gatherer(registry).
+		Metrics("batch_processed_total").
+		Grouping("instance", "test").
+		Interval(10 * time.Millisecond).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = task.Execute(ctx, cancel)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(bodies) > 0
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+	if err = task.Finalize(); err != nil {
+		t.Fatal(err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, "/metrics/job/batch/instance/test", paths[0])
+	assert.True(t, strings.Contains(bodies[0], "batch_processed_total"))
+	assert.False(t, strings.Contains(bodies[0], "batch_ignored_total"))
+}
@@ -11,7 +11,8 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package slices provides utility methods to manipulate slices (mostly slices of string).
+// Package slices provides utility methods to manipulate slices (mostly slices of string)
+// and generic helpers that are not provided by the standard library.
 package slices
 
 import "strings"
@@ -25,3 +26,49 @@ func InvertSubContains(a []string, x string) bool {
 	}
 	return false
 }
+
+// InvertSubContainsFold is the case-insensitive version of InvertSubContains
+func InvertSubContainsFold(a []string, x string) bool {
+	x = strings.ToLower(x)
+	for _, n := range a {
+		if strings.Contains(x, strings.ToLower(n)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsFold returns true if one of the string in a is equal to x under Unicode case-folding
+func ContainsFold(a []string, x string) bool {
+	for _, n := range a {
+		if strings.EqualFold(n, x) {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsPath returns true if one of the URL path in a is equal to the URL path x.
+// The query, the fragment and the trailing slash are ignored on both sides.
+func ContainsPath(a []string, x string) bool {
+	x = normalizePath(x)
+	for _, n := range a {
+		if normalizePath(n) == x {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizePath(p string) string {
+	if i := strings.IndexAny(p, "?#"); i >= 0 {
+		p = p[:i]
+	}
+	if len(p) > 1 {
+		p = strings.TrimRight(p, "/")
+		if len(p) == 0 {
+			p = "/"
+		}
+	}
+	return p
+}
@@ -61,3 +61,59 @@ func TestInvertSubContains(t *testing.T) {
 		})
 	}
 }
+
+func TestInvertSubContainsFold(t *testing.T) {
+	assert.True(t, InvertSubContainsFold([]string{"metrics"}, "/Metrics"))
+	assert.True(t, InvertSubContainsFold([]string{"Metrics"}, "/metrics"))
+	assert.False(t, InvertSubContainsFold([]string{"metrics"}, "/api"))
+}
+
+func TestContainsFold(t *testing.T) {
+	assert.True(t, ContainsFold([]string{"a", "GET"}, "get"))
+	assert.False(t, ContainsFold([]string{"a", "GET"}, "post"))
+	assert.False(t, ContainsFold(nil, "get"))
+}
+
+func TestContainsPath(t *testing.T) {
+	testSuites := []struct {
+		title  string
+		a      []string
+		x      string
+		result bool
+	}{
+		{
+			title:  "empty array",
+			x:      "/metrics",
+			result: false,
+		},
+		{
+			title:  "exact path",
+			a:      []string{"/metrics"},
+			x:      "/metrics",
+			result: true,
+		},
+		{
+			title:  "trailing slash and query",
+			a:      []string{"/metrics/"},
+			x:      "/metrics?foo=1",
+			result: true,
+		},
+		{
+			title:  "root path",
+			a:      []string{"/"},
+			x:      "/?foo=1",
+			result: true,
+		},
+		{
+			title:  "sub path",
+			a:      []string{"/metrics"},
+			x:      "/metrics/foo",
+			result: false,
+		},
+	}
+	for _, test := range testSuites {
+		t.Run(test.title, func(t *testing.T) {
+			assert.Equal(t, test.result, ContainsPath(test.a, test.x))
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slices
+
+// Dedupe returns a new slice without the duplicated elements of s. The order of the first occurrence of each element is kept.
+func Dedupe[T comparable](s []T) []T {
+	if s == nil {
+		return nil
+	}
+	seen := make(map[T]struct{}, len(s))
+	result := make([]T, 0, len(s))
+	for _, v := range s {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		result = append(result, v)
+	}
+	return result
+}
+
+// Chunk splits s into consecutive sub-slices of size n. The last chunk can be smaller than n.
+// The chunks share the same underlying array as s. It panics if n is lower than 1.
+func Chunk[T any](s []T, n int) [][]T {
+	if n < 1 {
+		panic("slices: chunk size must be greater than 0")
+	}
+	result := make([][]T, 0, (len(s)+n-1)/n)
+	for i := 0; i < len(s); i += n {
+		end := min(i+n, len(s))
+		result = append(result, s[i:end:end])
+	}
+	return result
+}
+
+// Partition returns the elements of s that satisfy the predicate and the ones that don't. The order is kept.
+func Partition[T any](s []T, predicate func(T) bool) ([]T, []T) {
+	var matched, unmatched []T
+	for _, v := range s {
+		if predicate(v) {
+			matched = append(matched, v)
+		} else {
+			unmatched = append(unmatched, v)
+		}
+	}
+	return matched, unmatched
+}
+
+// MapErr applies f to each element of s and returns the results. It stops at the first error returned by f.
+func MapErr[T any, U any](s []T, f func(T) (U, error)) ([]U, error) {
+	result := make([]U, 0, len(s))
+	for _, v := range s {
+		u, err := f(v)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
@@ -0,0 +1,104 @@
+// Copyright The Perses Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slices
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupe(t *testing.T) {
+	testSuites := []struct {
+		title  string
+		s      []string
+		result []string
+	}{
+		{
+			title:  "nil slice",
+			result: nil,
+		},
+		{
+			title:  "no duplicate",
+			s:      []string{"a", "b"},
+			result: []string{"a", "b"},
+		},
+		{
+			title:  "duplicates",
+			s:      []string{"b", "a", "b", "c", "a"},
+			result: []string{"b", "a", "c"},
+		},
+	}
+	for _, test := range testSuites {
+		t.Run(test.title, func(t *testing.T) {
+			assert.Equal(t, test.result, Dedupe(test.s))
+		})
+	}
+}
+
+func TestChunk(t *testing.T) {
+	testSuites := []struct {
+		title  string
+		s      []int
+		n      int
+		result [][]int
+	}{
+		{
+			title:  "empty slice",
+			n:      2,
+			result: [][]int{},
+		},
+		{
+			title:  "exact chunks",
+			s:      []int{1, 2, 3, 4},
+			n:      2,
+			result: [][]int{{1, 2}, {3, 4}},
+		},
+		{
+			title:  "last chunk smaller",
+			s:      []int{1, 2, 3, 4, 5},
+			n:      2,
+			result: [][]int{{1, 2}, {3, 4}, {5}},
+		},
+	}
+	for _, test := range testSuites {
+		t.Run(test.title, func(t *testing.T) {
+			assert.Equal(t, test.result, Chunk(test.s, test.n))
+		})
+	}
+	assert.Panics(t, func() { Chunk([]int{1}, 0) })
+}
+
+func TestPartition(t *testing.T) {
+	even, odd := Partition([]int{1, 2, 3, 4, 5}, func(i int) bool { return i%2 == 0 })
+	assert.Equal(t, []int{2, 4}, even)
+	assert.Equal(t, []int{1, 3, 5}, odd)
+}
+
+func TestMapErr(t *testing.T) {
+	result, err := MapErr([]string{"1", "2"}, strconv.Atoi)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, result)
+
+	_, err = MapErr([]string{"1", "a"}, func(s string) (int, error) {
+		i, convErr := strconv.Atoi(s)
+		if convErr != nil {
+			return 0, fmt.Errorf("invalid number %q", s)
+		}
+		return i, nil
+	})
+	assert.EqualError(t, err, `invalid number "a"`)
+}